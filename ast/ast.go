@@ -1,8 +1,11 @@
 package ast
 
+import "github.com/joetifa2003/windlang/token"
+
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Span() token.Span // the source range the node was parsed from
 }
 
 type Statement interface{ Node }
@@ -11,51 +11,61 @@ type Identifier struct {
 	Expression
 
 	Token token.Token // the token.IDENT token
+	Range token.Span
 	Value string
 }
 
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Span() token.Span     { return i.Range }
 func (i *Identifier) String() string       { return i.Value }
 
 type IntegerLiteral struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Value int
 }
 
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Span() token.Span     { return il.Range }
 func (il *IntegerLiteral) String() string       { return il.TokenLiteral() }
 
 type FloatLiteral struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Value float64
 }
 
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Span() token.Span     { return fl.Range }
 func (fl *FloatLiteral) String() string       { return fl.TokenLiteral() }
 
 type Boolean struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Value bool
 }
 
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Span() token.Span     { return b.Range }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type PrefixExpression struct {
 	Expression
 
 	Token    token.Token // The prefix token, e.g. !
+	Range    token.Span
 	Operator string
 	Right    Expression
 }
 
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Span() token.Span     { return pe.Range }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -69,12 +79,14 @@ type InfixExpression struct {
 	Expression
 
 	Token    token.Token // The operator token, e.g. +
+	Range    token.Span
 	Left     Expression
 	Operator string
 	Right    Expression
 }
 
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Span() token.Span     { return oe.Range }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -89,12 +101,14 @@ type IfExpression struct {
 	Expression
 
 	Token      token.Token // The 'if' token
+	Range      token.Span
 	Condition  Expression
 	ThenBranch Statement
 	ElseBranch Statement
 }
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Span() token.Span     { return ie.Range }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -114,11 +128,13 @@ type FunctionLiteral struct {
 	Expression
 
 	Token      token.Token // The 'fn' token
+	Range      token.Span
 	Parameters []*Identifier
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Span() token.Span     { return fl.Range }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -140,11 +156,13 @@ type CallExpression struct {
 	Expression
 
 	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral
+	Range     token.Span
+	Function  Expression // Identifier or FunctionLiteral
 	Arguments []Expression
 }
 
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Span() token.Span     { return ce.Range }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -165,21 +183,51 @@ type StringLiteral struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Value string
 }
 
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Span() token.Span     { return sl.Range }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// TemplateLiteral is a string with interpolations, its parts are the strings
+// around the interpolations and the interpolated expressions in source order
+type TemplateLiteral struct {
+	Expression
+
+	Token token.Token // the first TEMPLATE token
+	Range token.Span
+	Parts []Expression
+}
+
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) Span() token.Span     { return tl.Range }
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	for _, part := range tl.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 type PostfixExpression struct {
 	Expression
 
 	Token    token.Token
+	Range    token.Span
 	Left     Expression
 	Operator string
 }
 
 func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) Span() token.Span     { return pe.Range }
 func (pe *PostfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -193,18 +241,21 @@ func (pe *PostfixExpression) String() string {
 type AssignExpression struct {
 	Expression
 
-	Token token.Token
-	Name  Expression
-	Value Expression
+	Token    token.Token
+	Range    token.Span
+	Name     Expression
+	Operator string // the operator of a compound assignment like + for +=, empty for =
+	Value    Expression
 }
 
 func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Span() token.Span     { return ae.Range }
 func (ae *AssignExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ae.Name.String())
-	out.WriteString(" = " + ae.Value.String() + ")")
+	out.WriteString(" " + ae.Operator + "= " + ae.Value.String() + ")")
 
 	return out.String()
 }
@@ -213,10 +264,12 @@ type ArrayLiteral struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Value []Expression
 }
 
 func (ae *ArrayLiteral) TokenLiteral() string { return ae.Token.Literal }
+func (ae *ArrayLiteral) Span() token.Span     { return ae.Range }
 func (a *ArrayLiteral) Inspect() string {
 	var out bytes.Buffer
 
@@ -234,11 +287,13 @@ type IndexExpression struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Left  Expression
 	Index Expression
 }
 
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Span() token.Span     { return ie.Range }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -251,17 +306,53 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
-type NilLiteral struct{ Expression, Token token.Token }
+type NilLiteral struct {
+	Expression
+
+	Token token.Token
+	Range token.Span
+}
 
 func (ne *NilLiteral) TokenLiteral() string { return ne.Token.Literal }
+func (ne *NilLiteral) Span() token.Span     { return ne.Range }
 func (ne *NilLiteral) String() string       { return "nil" }
 
 type HashLiteral struct {
 	Expression
 
 	Token token.Token
+	Range token.Span
 	Pairs map[Expression]Expression
 }
 
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Span() token.Span     { return hl.Range }
 func (hl *HashLiteral) String() string       { return "hash" }
+
+// RangeExpression is start..end, the integers from start up to end excluded,
+// or start..=end with end included. start.. has no end
+type RangeExpression struct {
+	Expression
+
+	Token     token.Token // the '..' or '..=' token
+	Range     token.Span
+	Start     Expression
+	End       Expression // nil if the range has no end
+	Inclusive bool
+}
+
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) Span() token.Span     { return re.Range }
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.Token.Literal)
+	if re.End != nil {
+		out.WriteString(re.End.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
@@ -2,12 +2,14 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/joetifa2003/windlang/token"
 )
 
 type Program struct {
 	Statements []Statement
+	Range      token.Span
 }
 
 func (p *Program) TokenLiteral() string {
@@ -18,6 +20,8 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.Span { return p.Range }
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -33,15 +37,22 @@ type LetStatement struct {
 	Statement
 
 	Token    token.Token // the token.LET token
+	Range    token.Span
 	Name     *Identifier
 	Value    Expression
 	Constant bool
+	Exported bool // declared with export at the top level of a file, the files including it can use it
 }
 
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Span() token.Span     { return ls.Range }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
+	if ls.Exported {
+		out.WriteString("export ")
+	}
+
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
 	out.WriteString(" = ")
@@ -58,10 +69,12 @@ type ReturnStatement struct {
 	Statement
 
 	Token       token.Token // the 'return' token
+	Range       token.Span
 	ReturnValue Expression
 }
 
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Span() token.Span     { return rs.Range }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -78,10 +91,12 @@ type ExpressionStatement struct {
 	Statement
 
 	Token      token.Token // the first token of the expression
+	Range      token.Span
 	Expression Expression
 }
 
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Span() token.Span     { return es.Range }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -94,11 +109,13 @@ type BlockStatement struct {
 	Statement
 
 	Token      token.Token // the { token
+	Range      token.Span
 	Statements []Statement
 	VarCount   int
 }
 
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Span() token.Span     { return bs.Range }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -113,6 +130,7 @@ type ForStatement struct {
 	Statement
 
 	Token       token.Token // the 'for' token
+	Range       token.Span
 	Initializer Statement
 	Condition   Expression
 	Increment   Expression
@@ -120,24 +138,81 @@ type ForStatement struct {
 }
 
 func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Span() token.Span     { return fs.Range }
 func (fs *ForStatement) String() string {
 	return ""
 }
 
+// ForInStatement is for (value in iterable) or for (key, value in iterable),
+// the key is the index of the value for arrays, strings and ranges.
+// A loop over a hash with only a value steps over the keys of the hash
+type ForInStatement struct {
+	Statement
+
+	Token    token.Token // the 'for' token
+	Range    token.Span
+	Key      *Identifier // nil if only the value is declared
+	Value    *Identifier
+	Iterable Expression
+	Body     Statement
+}
+
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) Span() token.Span     { return fs.Range }
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String() + " in " + fs.Iterable.String() + ") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
 type IncludeStatement struct {
 	Statement
 
-	Token token.Token // the 'include' token
-	Path  string
+	Token   token.Token // the 'include' token
+	Range   token.Span
+	Path    string // as written in the script, the module loader resolves it when the file is included
+	Alias   *Identifier
+	Imports []*Import // the variables imported with import { a, b as c }, nil if there is no import list
+}
+
+// Import is a variable imported from an included file, Alias is the name it's declared with in the file including it
+type Import struct {
+	Name  *Identifier
 	Alias *Identifier
 }
 
 func (is *IncludeStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *IncludeStatement) Span() token.Span     { return is.Range }
 func (is *IncludeStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(is.TokenLiteral() + " ")
 	out.WriteString(is.Path)
+
+	if is.Alias != nil {
+		out.WriteString(" as " + is.Alias.String())
+	}
+
+	if is.Imports != nil {
+		imports := []string{}
+		for _, imp := range is.Imports {
+			if imp.Alias.Value != imp.Name.Value {
+				imports = append(imports, imp.Name.String()+" as "+imp.Alias.String())
+			} else {
+				imports = append(imports, imp.Name.String())
+			}
+		}
+
+		out.WriteString(" import { " + strings.Join(imports, ", ") + " }")
+	}
+
 	out.WriteString(";")
 
 	return out.String()
@@ -147,11 +222,13 @@ type WhileStatement struct {
 	Statement
 
 	Token     token.Token
+	Range     token.Span
 	Condition Expression
 	Body      Statement
 }
 
 func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Span() token.Span     { return ws.Range }
 func (ws *WhileStatement) String() string {
 	return ""
 }
@@ -160,8 +237,81 @@ type EchoStatement struct {
 	Statement
 
 	Token token.Token
+	Range token.Span
 	Value Expression
 }
 
 func (es *EchoStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EchoStatement) Span() token.Span     { return es.Range }
 func (es *EchoStatement) String() string       { return "" }
+
+type BreakStatement struct {
+	Statement
+
+	Token token.Token // the 'break' token
+	Range token.Span
+}
+
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Span() token.Span     { return bs.Range }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Statement
+
+	Token token.Token // the 'continue' token
+	Range token.Span
+}
+
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Span() token.Span     { return cs.Range }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+type TryStatement struct {
+	Statement
+
+	Token      token.Token // the 'try' token
+	Range      token.Span
+	Body       *BlockStatement
+	CatchParam *Identifier     // nil if the caught value is not bound
+	Catch      *BlockStatement // nil if there is no catch block
+	Finally    *BlockStatement // nil if there is no finally block
+}
+
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) Span() token.Span     { return ts.Range }
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(ts.Body.String())
+
+	if ts.Catch != nil {
+		out.WriteString(" catch ")
+		if ts.CatchParam != nil {
+			out.WriteString("(" + ts.CatchParam.String() + ") ")
+		}
+		out.WriteString(ts.Catch.String())
+	}
+
+	if ts.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(ts.Finally.String())
+	}
+
+	return out.String()
+}
+
+type ThrowStatement struct {
+	Statement
+
+	Token token.Token // the 'throw' token
+	Range token.Span
+	Value Expression
+}
+
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Span() token.Span     { return ts.Range }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
@@ -0,0 +1,563 @@
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/value"
+)
+
+// Magic is the header every bytecode file starts with
+const Magic = "WNDC"
+
+// Version is bumped whenever the layout of the file or the opcodes change,
+// files with another version are rejected instead of being misread
+const Version = 7
+
+// Program is a compiled script, it holds everything the vm needs to run it
+type Program struct {
+	SourceFile string
+	Constants  []value.Value
+	Main       *value.Function
+}
+
+// the tags of the typed entries of the constant pool
+const (
+	tagNil byte = iota
+	tagInt
+	tagFloat
+	tagBool
+	tagString
+	tagFunction
+)
+
+// IsBytecode reports whether the data starts with the bytecode header
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// Encode writes the program in the following layout, integers are varints
+//
+//	magic, version
+//	source file name
+//	constant pool: count, then a tag and a payload for each constant
+//	main function
+//
+// functions are written as name, file, arity, local count, upvalues,
+// instructions and a run length encoded line table
+func Encode(w io.Writer, program *Program) error {
+	e := encoder{}
+
+	e.buf = append(e.buf, Magic...)
+	e.uint(Version)
+	e.string(program.SourceFile)
+
+	e.uint(len(program.Constants))
+	for _, constant := range program.Constants {
+		if err := e.constant(constant); err != nil {
+			return err
+		}
+	}
+
+	e.function(program.Main)
+
+	_, err := w.Write(e.buf)
+
+	return err
+}
+
+// Decode reads a program written by Encode
+func Decode(r io.Reader) (*Program, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsBytecode(data) {
+		return nil, fmt.Errorf("not a wind bytecode file")
+	}
+
+	d := decoder{r: bytes.NewReader(data[len(Magic):])}
+
+	version := d.uint()
+	if d.err == nil && version != Version {
+		return nil, fmt.Errorf("unsupported bytecode version %d, expected %d", version, Version)
+	}
+
+	program := &Program{}
+	program.SourceFile = d.string()
+
+	constantsCount := d.count()
+	for i := 0; i < constantsCount && d.err == nil; i++ {
+		program.Constants = append(program.Constants, d.constant())
+	}
+
+	program.Main = d.function()
+
+	if d.err == nil {
+		d.err = verify(program)
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("corrupted bytecode file: %w", d.err)
+	}
+
+	return program, nil
+}
+
+// maxLocals is the most variables a function or a block can declare, it bounds what the vm allocates for them
+const maxLocals = 1 << 16
+
+// verify checks the operands of every function against the constant pool, its own instructions
+// and the variables in scope, so a file that was cut or edited fails to decode instead of making the vm index out of range.
+// It doesn't follow how many values each instruction leaves on the stack, so a file edited to pop more than it pushed can still crash the vm
+func verify(program *Program) error {
+	if err := verifyFunction(program.Constants, program.Main, true); err != nil {
+		return err
+	}
+
+	for _, constant := range program.Constants {
+		if constant.VType != value.VALUE_FUNCTION {
+			continue
+		}
+
+		if err := verifyFunction(program.Constants, constant.GetFunction().Fn, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyFunction checks a function, main is run without the scope of its variables, it only declares them in blocks
+func verifyFunction(constants []value.Value, fn *value.Function, main bool) error {
+	if fn.LocalCount > maxLocals {
+		return fmt.Errorf("function %s has %d locals, the most is %d", fn.Name, fn.LocalCount, maxLocals)
+	}
+
+	for offset := 0; offset < len(fn.Instructions); {
+		op := fn.Instructions[offset]
+		if op < 0 || op > opcode.OP_ERROR {
+			return fmt.Errorf("unknown opcode %d at %04d in %s", int(op), offset, fn.Name)
+		}
+
+		end := offset + 1 + op.OperandCount()
+		if end > len(fn.Instructions) {
+			return fmt.Errorf("%s at %04d in %s is missing operands", op, offset, fn.Name)
+		}
+
+		operands := fn.Instructions[offset+1 : end]
+
+		switch op {
+		case opcode.OP_CONST, opcode.OP_CLOSURE, opcode.OP_GET_BUILTIN, opcode.OP_INCLUDE, opcode.OP_EXPORT, opcode.OP_ERROR:
+			index := int(operands[0])
+			if index < 0 || index >= len(constants) {
+				return fmt.Errorf("%s at %04d in %s uses constant %d, there are %d", op, offset, fn.Name, index, len(constants))
+			}
+
+			vType := constants[index].VType
+			valid := true
+			switch op {
+			case opcode.OP_CLOSURE:
+				valid = vType == value.VALUE_FUNCTION
+			case opcode.OP_GET_BUILTIN, opcode.OP_EXPORT, opcode.OP_ERROR:
+				valid = vType == value.VALUE_STRING
+			case opcode.OP_INCLUDE:
+				valid = vType == value.VALUE_FUNCTION || vType == value.VALUE_STRING
+			}
+
+			if !valid {
+				return fmt.Errorf("%s at %04d in %s can't use constant %d of type %s", op, offset, fn.Name, index, vType)
+			}
+
+		case opcode.OP_JUMP, opcode.OP_JUMP_FALSE, opcode.OP_TRY, opcode.OP_NEXT:
+			target := offset + 1 + int(operands[0])
+			if target < 0 || target > len(fn.Instructions) {
+				return fmt.Errorf("%s at %04d in %s jumps to %04d, outside of the function", op, offset, fn.Name, target)
+			}
+
+		case opcode.OP_BLOCK:
+			if int(operands[0]) < 0 || int(operands[0]) > maxLocals {
+				return fmt.Errorf("%s at %04d in %s declares %d variables, the most is %d", op, offset, fn.Name, operands[0], maxLocals)
+			}
+
+		case opcode.OP_GET_UPVALUE, opcode.OP_SET_UPVALUE:
+			if int(operands[0]) < 0 || int(operands[0]) >= len(fn.Upvalues) {
+				return fmt.Errorf("%s at %04d in %s uses upvalue %d, there are %d", op, offset, fn.Name, operands[0], len(fn.Upvalues))
+			}
+
+		case opcode.OP_RANGE:
+			if operands[0] != opcode.RANGE_EXCLUSIVE && operands[0] != opcode.RANGE_INCLUSIVE && operands[0] != opcode.RANGE_OPEN {
+				return fmt.Errorf("%s at %04d in %s has unknown kind %d", op, offset, fn.Name, operands[0])
+			}
+		}
+
+		offset = end
+	}
+
+	if main {
+		return verifyScopes(constants, fn, nil)
+	}
+
+	return verifyScopes(constants, fn, []int{fn.LocalCount})
+}
+
+// verifyScopes follows every path through the function with the sizes of the scopes open at each instruction,
+// starting with the function's own scope, so variable operands can be checked against the scopes that exist when they run.
+// Every path must reach an instruction with the same scopes open, which is how the compiler emits them
+func verifyScopes(constants []value.Value, fn *value.Function, scope []int) error {
+	instructions := fn.Instructions
+
+	starts := make([]bool, len(instructions)+1)
+	for offset := 0; offset < len(instructions); offset += 1 + instructions[offset].OperandCount() {
+		starts[offset] = true
+	}
+	starts[len(instructions)] = true
+
+	scopes := make([][]int, len(instructions)+1)
+	visited := make([]bool, len(instructions)+1)
+	scopes[0], visited[0] = scope, true
+	work := []int{0}
+
+	visit := func(from, target int, sizes []int) error {
+		if !starts[target] {
+			return fmt.Errorf("%s at %04d in %s jumps into the middle of an instruction", instructions[from], from, fn.Name)
+		}
+
+		if !visited[target] {
+			scopes[target], visited[target] = sizes, true
+			work = append(work, target)
+			return nil
+		}
+
+		if !sameSizes(scopes[target], sizes) {
+			return fmt.Errorf("%s at %04d in %s reaches %04d with different scopes open", instructions[from], from, fn.Name, target)
+		}
+
+		return nil
+	}
+
+	for len(work) > 0 {
+		offset := work[len(work)-1]
+		work = work[:len(work)-1]
+		if offset == len(instructions) {
+			continue
+		}
+
+		sizes := scopes[offset]
+		op := instructions[offset]
+		operands := instructions[offset+1 : offset+1+op.OperandCount()]
+		next := offset + 1 + len(operands)
+
+		switch op {
+		case opcode.OP_GET, opcode.OP_SET, opcode.OP_INC:
+			index, scope := int(operands[0]), int(operands[1])
+			if scope < 0 || scope >= len(sizes) {
+				return fmt.Errorf("%s at %04d in %s uses scope %d, there are %d", op, offset, fn.Name, scope, len(sizes))
+			}
+			if index < 0 || index >= sizes[scope] {
+				return fmt.Errorf("%s at %04d in %s uses variable %d of scope %d, there are %d", op, offset, fn.Name, index, scope, sizes[scope])
+			}
+
+		case opcode.OP_LET:
+			index := int(operands[0])
+			if len(sizes) == 0 {
+				return fmt.Errorf("%s at %04d in %s declares a variable outside of a scope", op, offset, fn.Name)
+			}
+			if index < 0 || index >= sizes[len(sizes)-1] {
+				return fmt.Errorf("%s at %04d in %s declares variable %d, there are %d", op, offset, fn.Name, index, sizes[len(sizes)-1])
+			}
+
+		case opcode.OP_CLOSURE:
+			for i, upvalue := range constants[operands[0]].GetFunction().Fn.Upvalues {
+				valid := upvalue.Index >= 0 && upvalue.Index < len(fn.Upvalues)
+				if upvalue.Local {
+					valid = upvalue.Scope >= 0 && upvalue.Scope < len(sizes) && upvalue.Index >= 0 && upvalue.Index < sizes[upvalue.Scope]
+				}
+
+				if !valid {
+					return fmt.Errorf("%s at %04d in %s captures upvalue %d from a variable that doesn't exist", op, offset, fn.Name, i)
+				}
+			}
+
+		case opcode.OP_BLOCK:
+			sizes = append(sizes[:len(sizes):len(sizes)], int(operands[0]))
+
+		case opcode.OP_END_BLOCK:
+			if len(sizes) == len(scope) {
+				return fmt.Errorf("%s at %04d in %s closes a block that isn't open", op, offset, fn.Name)
+			}
+			sizes = sizes[:len(sizes)-1]
+
+		case opcode.OP_JUMP:
+			if err := visit(offset, offset+1+int(operands[0]), sizes); err != nil {
+				return err
+			}
+			continue
+
+		case opcode.OP_JUMP_FALSE, opcode.OP_TRY, opcode.OP_NEXT:
+			if err := visit(offset, offset+1+int(operands[0]), sizes); err != nil {
+				return err
+			}
+
+		case opcode.OP_RETURN, opcode.OP_THROW, opcode.OP_ERROR:
+			continue
+		}
+
+		if err := visit(offset, next, sizes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameSizes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(v int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *encoder) int(v int) {
+	e.buf = binary.AppendVarint(e.buf, int64(v))
+}
+
+func (e *encoder) string(s string) {
+	e.uint(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) constant(v value.Value) error {
+	switch v.VType {
+	case value.VALUE_NIL:
+		e.buf = append(e.buf, tagNil)
+
+	case value.VALUE_INT:
+		e.buf = append(e.buf, tagInt)
+		e.int(v.GetInt())
+
+	case value.VALUE_FLOAT:
+		e.buf = append(e.buf, tagFloat)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.GetFloat()))
+
+	case value.VALUE_BOOL:
+		e.buf = append(e.buf, tagBool)
+		if v.GetBool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+
+	case value.VALUE_STRING:
+		e.buf = append(e.buf, tagString)
+		e.string(v.GetString())
+
+	case value.VALUE_FUNCTION:
+		e.buf = append(e.buf, tagFunction)
+		e.function(v.GetFunction().Fn)
+
+	default:
+		return fmt.Errorf("cannot encode a constant of type %s", v.VType)
+	}
+
+	return nil
+}
+
+func (e *encoder) function(fn *value.Function) {
+	e.string(fn.Name)
+	e.string(fn.File)
+	e.uint(fn.Arity)
+	e.uint(fn.LocalCount)
+
+	e.uint(len(fn.Upvalues))
+	for _, upvalue := range fn.Upvalues {
+		if upvalue.Local {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+		e.uint(upvalue.Scope)
+		e.uint(upvalue.Index)
+	}
+
+	e.uint(len(fn.Instructions))
+	for _, instruction := range fn.Instructions {
+		e.int(int(instruction))
+	}
+
+	// consecutive instructions mostly share a line, so the table stores runs of lines
+	runs := [][2]int{}
+	for _, line := range fn.Lines {
+		if len(runs) != 0 && runs[len(runs)-1][1] == line {
+			runs[len(runs)-1][0]++
+			continue
+		}
+
+		runs = append(runs, [2]int{1, line})
+	}
+
+	e.uint(len(runs))
+	for _, run := range runs {
+		e.uint(run[0])
+		e.uint(run[1])
+	}
+}
+
+// decoder keeps the first error and returns zero values after it, callers check err once at the end
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *decoder) uint() int {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+
+	return int(v)
+}
+
+func (d *decoder) int() int {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+
+	return int(v)
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	b, err := d.r.ReadByte()
+	d.err = err
+
+	return b
+}
+
+// count reads a length that can't be bigger than the bytes left, which guards the allocations
+func (d *decoder) count() int {
+	n := d.uint()
+	if d.err == nil && n > d.r.Len() {
+		d.err = fmt.Errorf("length %d is bigger than the file", n)
+		return 0
+	}
+
+	return n
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+
+	buf := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, buf)
+
+	return string(buf)
+}
+
+func (d *decoder) constant() value.Value {
+	tag := d.byte()
+
+	switch tag {
+	case tagNil:
+		return value.NewNilValue()
+
+	case tagInt:
+		return value.NewIntValue(d.int())
+
+	case tagFloat:
+		buf := make([]byte, 8)
+		if d.err == nil {
+			_, d.err = io.ReadFull(d.r, buf)
+		}
+
+		return value.NewFloatValue(math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+
+	case tagBool:
+		return value.NewBoolValue(d.byte() == 1)
+
+	case tagString:
+		return value.NewStringValue(d.string())
+
+	case tagFunction:
+		return value.NewFunctionValue(&value.Closure{Fn: d.function()})
+	}
+
+	if d.err == nil {
+		d.err = fmt.Errorf("unknown constant tag %d", tag)
+	}
+
+	return value.NewNilValue()
+}
+
+func (d *decoder) function() *value.Function {
+	fn := &value.Function{}
+
+	fn.Name = d.string()
+	fn.File = d.string()
+	fn.Arity = d.uint()
+	fn.LocalCount = d.uint()
+
+	upvaluesCount := d.count()
+	for i := 0; i < upvaluesCount && d.err == nil; i++ {
+		fn.Upvalues = append(fn.Upvalues, value.Upvalue{
+			Local: d.byte() == 1,
+			Scope: d.uint(),
+			Index: d.uint(),
+		})
+	}
+
+	instructionsCount := d.count()
+	for i := 0; i < instructionsCount && d.err == nil; i++ {
+		fn.Instructions = append(fn.Instructions, opcode.OpCode(d.int()))
+	}
+
+	runsCount := d.count()
+	for i := 0; i < runsCount && d.err == nil; i++ {
+		n, line := d.uint(), d.uint()
+		if len(fn.Lines)+n > len(fn.Instructions) {
+			d.err = fmt.Errorf("line table is longer than the instructions")
+			break
+		}
+
+		for j := 0; j < n; j++ {
+			fn.Lines = append(fn.Lines, line)
+		}
+	}
+
+	if d.err == nil && len(fn.Lines) != len(fn.Instructions) {
+		d.err = fmt.Errorf("line table doesn't match the instructions of %s", fn.Name)
+	}
+
+	return fn
+}
@@ -0,0 +1,162 @@
+package bytecode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joetifa2003/windlang/compiler"
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/value"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compile(t *testing.T, input string) *Program {
+	parser := parser.New(lexer.New(input), "test.wind")
+	program := parser.ParseProgram()
+	assert.Empty(t, parser.ReportErrors())
+
+	compiler := compiler.NewCompiler("test.wind")
+	main := compiler.Compile(program)
+	assert.Empty(t, compiler.ReportErrors())
+
+	return &Program{SourceFile: "test.wind", Constants: compiler.Constants, Main: main}
+}
+
+func TestRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	program := compile(t, `
+		let makeCounter = fn(step) {
+			let count = 0;
+			fn() { count = count + step; count }
+		};
+		let counter = makeCounter(-2);
+		let hash = {"pi": 3.14, "ok": true, "nothing": nil};
+		echo "count: " + string(counter());
+	`)
+
+	var buf bytes.Buffer
+	assert.Nil(Encode(&buf, program))
+
+	decoded, err := Decode(&buf)
+	assert.Nil(err)
+	assert.Equal(program, decoded)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Encode(&buf, compile(t, `let x = "hello";`)))
+	data := buf.Bytes()
+
+	testCases := []struct {
+		input    []byte
+		expected string
+	}{
+		{[]byte("let x = 1;"), "not a wind bytecode file"},
+		{[]byte(Magic + "\x08"), "unsupported bytecode version 8, expected 7"},
+		{data[:len(data)-3], "corrupted bytecode file: EOF"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Decode(bytes.NewReader(testCase.input))
+		if assert.NotNil(err) {
+			assert.Equal(testCase.expected, err.Error())
+		}
+	}
+}
+
+func TestDecodeCorruptedSlot(t *testing.T) {
+	assert := assert.New(t)
+
+	program := compile(t, `
+		let add = fn(a, b) { a + b };
+		echo add(1, 2);
+	`)
+
+	add := program.Constants[0].GetFunction().Fn
+	assert.Equal(opcode.OP_GET, add.Instructions[0])
+	add.Instructions[1] = 500
+
+	var buf bytes.Buffer
+	assert.Nil(Encode(&buf, program))
+
+	_, err := Decode(&buf)
+	if assert.NotNil(err) {
+		assert.Equal("corrupted bytecode file: OP_GET at 0000 in add uses variable 500 of scope 0, there are 2", err.Error())
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	assert := assert.New(t)
+
+	program := compile(t, `
+		let x = 2;
+		if (x > 1) { echo "big"; }
+	`)
+
+	var buf bytes.Buffer
+	Disassemble(&buf, program)
+	out := buf.String()
+
+	assert.Contains(out, "== <main> test.wind ==")
+	assert.Contains(out, "OP_BLOCK         1 vars")
+	assert.Contains(out, "OP_CONST         0 (2)")
+	assert.Contains(out, "OP_GET           slot 0 scope 0")
+	assert.Contains(out, `("big")`)
+	assert.Regexp(`OP_JUMP_FALSE    \d+ -> \d{4}`, out)
+}
+
+func TestDecodeInvalidOperands(t *testing.T) {
+	assert := assert.New(t)
+
+	main := func(localCount int, instructions ...opcode.OpCode) *Program {
+		return &Program{
+			SourceFile: "test.wind",
+			Constants:  []value.Value{value.NewIntValue(1)},
+			Main: &value.Function{
+				Name:         "<main>",
+				LocalCount:   localCount,
+				Instructions: instructions,
+				Lines:        make([]int, len(instructions)),
+			},
+		}
+	}
+
+	testCases := []struct {
+		program  *Program
+		expected string
+	}{
+		{main(0, opcode.OP_CONST, 1), "OP_CONST at 0000 in <main> uses constant 1, there are 1"},
+		{main(0, opcode.OP_CLOSURE, 0), "OP_CLOSURE at 0000 in <main> can't use constant 0 of type INTEGER"},
+		{main(0, opcode.OP_JUMP, 5), "OP_JUMP at 0000 in <main> jumps to 0006, outside of the function"},
+		{main(0, opcode.OP_JUMP, -3), "OP_JUMP at 0000 in <main> jumps to -002, outside of the function"},
+		{main(0, opcode.OP_CONST), "OP_CONST at 0000 in <main> is missing operands"},
+		{main(0, opcode.OP_GET_UPVALUE, 0), "OP_GET_UPVALUE at 0000 in <main> uses upvalue 0, there are 0"},
+		{main(0, opcode.OP_BLOCK, 1<<20), "OP_BLOCK at 0000 in <main> declares 1048576 variables, the most is 65536"},
+		{main(0, 1000), "unknown opcode 1000 at 0000 in <main>"},
+		{main(0, opcode.OP_RANGE, 7), "OP_RANGE at 0000 in <main> has unknown kind 7"},
+		{main(0, opcode.OP_GET, 0, 0), "OP_GET at 0000 in <main> uses scope 0, there are 0"},
+		{main(0, opcode.OP_BLOCK, 2, opcode.OP_SET, 2, 0), "OP_SET at 0002 in <main> uses variable 2 of scope 0, there are 2"},
+		{main(0, opcode.OP_BLOCK, 1, opcode.OP_INC, 0, 1), "OP_INC at 0002 in <main> uses scope 1, there are 1"},
+		{main(0, opcode.OP_CONST, 0, opcode.OP_LET, 0), "OP_LET at 0002 in <main> declares a variable outside of a scope"},
+		{main(0, opcode.OP_END_BLOCK), "OP_END_BLOCK at 0000 in <main> closes a block that isn't open"},
+		{main(0, opcode.OP_BLOCK, 1, opcode.OP_JUMP, -3), "OP_JUMP at 0002 in <main> reaches 0000 with different scopes open"},
+		{main(0, opcode.OP_JUMP, 2, opcode.OP_CONST, 0), "OP_JUMP at 0000 in <main> jumps into the middle of an instruction"},
+		{main(1 << 20), "function <main> has 1048576 locals, the most is 65536"},
+	}
+
+	for _, testCase := range testCases {
+		var buf bytes.Buffer
+		assert.Nil(Encode(&buf, testCase.program))
+
+		_, err := Decode(&buf)
+		if assert.NotNil(err, testCase.expected) {
+			assert.Equal("corrupted bytecode file: "+testCase.expected, err.Error())
+		}
+	}
+}
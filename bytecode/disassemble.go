@@ -0,0 +1,130 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/value"
+)
+
+// Disassemble writes a listing of the main function followed by every function in the constant pool
+func Disassemble(w io.Writer, program *Program) {
+	fmt.Fprintf(w, "== <main> %s ==\n", program.SourceFile)
+	disassembleFunction(w, program.Constants, program.Main)
+
+	for index, constant := range program.Constants {
+		if constant.VType != value.VALUE_FUNCTION {
+			continue
+		}
+
+		fn := constant.GetFunction().Fn
+		fmt.Fprintf(w, "\n== constant %d: fn %s (%d args, %d locals) ==\n", index, fn.Name, fn.Arity, fn.LocalCount)
+		for i, upvalue := range fn.Upvalues {
+			if upvalue.Local {
+				fmt.Fprintf(w, "upvalue %d: slot %d scope %d\n", i, upvalue.Index, upvalue.Scope)
+			} else {
+				fmt.Fprintf(w, "upvalue %d: upvalue %d\n", i, upvalue.Index)
+			}
+		}
+
+		disassembleFunction(w, program.Constants, fn)
+	}
+}
+
+func disassembleFunction(w io.Writer, constants []value.Value, fn *value.Function) {
+	for offset := 0; offset < len(fn.Instructions); {
+		offset = DisassembleInstruction(w, constants, fn, offset)
+	}
+}
+
+// DisassembleInstruction writes the instruction at the offset on one line as
+// offset, source line (| if it's the line of the previous instruction), opcode and decoded operands,
+// it returns the offset of the next instruction
+func DisassembleInstruction(w io.Writer, constants []value.Value, fn *value.Function, offset int) int {
+	op := fn.Instructions[offset]
+
+	line := "   |"
+	if offset == 0 || fn.Lines[offset] != fn.Lines[offset-1] {
+		line = fmt.Sprintf("%4d", fn.Lines[offset])
+	}
+
+	end := offset + 1 + op.OperandCount()
+	if end > len(fn.Instructions) {
+		fmt.Fprintf(w, "%04d %s %s missing operands\n", offset, line, op)
+		return len(fn.Instructions)
+	}
+
+	operands := fn.Instructions[offset+1 : end]
+	fmt.Fprintf(w, "%04d %s %-16s %s\n", offset, line, op, describeOperands(constants, op, offset, operands))
+
+	return end
+}
+
+func describeOperands(constants []value.Value, op opcode.OpCode, offset int, operands []opcode.OpCode) string {
+	switch op {
+	case opcode.OP_CONST, opcode.OP_CLOSURE, opcode.OP_GET_BUILTIN, opcode.OP_INCLUDE, opcode.OP_EXPORT, opcode.OP_ERROR:
+		index := int(operands[0])
+		if index < 0 || index >= len(constants) {
+			return fmt.Sprintf("%d (out of range)", index)
+		}
+
+		return fmt.Sprintf("%d (%s)", index, Repr(constants[index]))
+
+	case opcode.OP_LET:
+		return fmt.Sprintf("slot %d", operands[0])
+
+	case opcode.OP_GET, opcode.OP_SET, opcode.OP_INC:
+		return fmt.Sprintf("slot %d scope %d", operands[0], operands[1])
+
+	case opcode.OP_JUMP, opcode.OP_JUMP_FALSE, opcode.OP_TRY, opcode.OP_NEXT:
+		return fmt.Sprintf("%d -> %04d", operands[0], offset+1+int(operands[0]))
+
+	case opcode.OP_BLOCK:
+		return fmt.Sprintf("%d vars", operands[0])
+
+	case opcode.OP_RANGE:
+		switch operands[0] {
+		case opcode.RANGE_INCLUSIVE:
+			return "..="
+		case opcode.RANGE_OPEN:
+			return "open"
+		default:
+			return ".."
+		}
+
+	case opcode.OP_ARRAY:
+		return fmt.Sprintf("%d elements", operands[0])
+
+	case opcode.OP_TEMPLATE:
+		return fmt.Sprintf("%d parts", operands[0])
+
+	case opcode.OP_CALL:
+		return fmt.Sprintf("%d args", operands[0])
+
+	case opcode.OP_GET_UPVALUE, opcode.OP_SET_UPVALUE:
+		return fmt.Sprintf("upvalue %d", operands[0])
+	}
+
+	strs := []string{}
+	for _, operand := range operands {
+		strs = append(strs, fmt.Sprint(int(operand)))
+	}
+
+	return strings.Join(strs, " ")
+}
+
+// Repr formats a value for debugging, unlike String it quotes strings and names functions
+func Repr(v value.Value) string {
+	switch v.VType {
+	case value.VALUE_STRING:
+		return fmt.Sprintf("%q", v.GetString())
+	case value.VALUE_FUNCTION:
+		return "fn " + v.GetFunction().Fn.Name
+	case value.VALUE_BUILTIN:
+		return "builtin " + v.GetBuiltin().Name
+	}
+
+	return v.String()
+}
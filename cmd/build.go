@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joetifa2003/windlang/bytecode"
+	"github.com/spf13/cobra"
+)
+
+var (
+	output = ""
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build [file]",
+	Short: "Compile a Wind script to a .windc bytecode file",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("requires 1 argument")
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		// cleaned so the includes that resolve back to the file use the same path
+		filePath := filepath.Clean(args[0])
+
+		file, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Fatalln("Could not read file:", err)
+			return
+		}
+
+		program := compileFile(filePath, string(file))
+
+		outputPath := output
+		if outputPath == "" {
+			outputPath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".windc"
+		}
+
+		out, err := os.Create(outputPath)
+		if err != nil {
+			log.Fatalln("Could not create output file:", err)
+		}
+		defer out.Close()
+
+		if err := bytecode.Encode(out, program); err != nil {
+			log.Fatalln("Could not write bytecode:", err)
+		}
+	},
+}
+
+func init() {
+	buildCmd.Flags().StringVarP(&output, "output", "o", "", "Output file, defaults to the script name with the .windc extension")
+	addPermissionFlags(buildCmd)
+	rootCmd.AddCommand(buildCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/joetifa2003/windlang/loader"
+	"github.com/joetifa2003/windlang/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	allowFS      []string
+	allowNet     = false
+	allowModules []string
+	allowAll     = false
+)
+
+// addPermissionFlags adds the flags that choose what the scripts run by the command can access
+func addPermissionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&allowFS, "allow-fs", nil, "Directories the script can read and write with the fs module and include files from")
+	cmd.Flags().BoolVar(&allowNet, "allow-net", false, "Allow the script to make network requests")
+	cmd.Flags().StringSliceVar(&allowModules, "allow-modules", nil, "Std library modules the script can include, defaults to all of them")
+	cmd.Flags().BoolVar(&allowAll, "allow-all", false, "Allow the script to access everything")
+}
+
+// permissions returns the permissions chosen by the flags, scripts can always include the files
+// of the working directory, of the directory of the script, of its project and of the search path
+func permissions(filePath string) *sandbox.Permissions {
+	if allowAll {
+		return nil
+	}
+
+	includeRoots := append([]string{".", filepath.Dir(filePath)}, loader.NewOS().SearchPath...)
+	if root, ok := loader.ProjectRoot(filepath.Dir(filePath)); ok {
+		includeRoots = append(includeRoots, root)
+	}
+
+	return &sandbox.Permissions{
+		Modules:      allowModules,
+		FSRoots:      allowFS,
+		IncludeRoots: includeRoots,
+		AllowNet:     allowNet,
+	}
+}
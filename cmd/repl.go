@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/joetifa2003/windlang/repl"
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive Wind session",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		historyPath := ""
+		if home, err := os.UserHomeDir(); err == nil {
+			historyPath = filepath.Join(home, ".wind_history")
+		}
+
+		repl.New(os.Stdin, os.Stdout, historyPath).Start()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+}
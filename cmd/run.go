@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/joetifa2003/windlang/evaluator"
 	"github.com/joetifa2003/windlang/lexer"
@@ -24,11 +25,13 @@ var runCmd = &cobra.Command{
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		filePath := args[0]
-		if filePath == "" {
+		if args[0] == "" {
 			log.Fatal("File path is required")
 		}
 
+		// cleaned so the includes that resolve back to the file use the same path
+		filePath := filepath.Clean(args[0])
+
 		file, err := os.ReadFile(filePath)
 		if err != nil {
 			log.Fatalln("Could not read file:", err)
@@ -49,11 +52,14 @@ var runCmd = &cobra.Command{
 		}
 
 		envManager := evaluator.NewEnvironmentManager()
+		envManager.SetSource(filePath, input)
 		env, _ := envManager.Get(filePath)
 		ev := evaluator.New(envManager, filePath)
+		ev.SetPermissions(permissions(filePath))
 		evaluated, evErr := ev.Eval(program, env, nil)
 		if evErr != nil {
 			fmt.Println(evErr.Inspect())
+			fmt.Println(evErr.StackTrace())
 		}
 
 		if evaluated == nil {
@@ -63,5 +69,6 @@ var runCmd = &cobra.Command{
 }
 
 func init() {
+	addPermissionFlags(runCmd)
 	rootCmd.AddCommand(runCmd)
 }
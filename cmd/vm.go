@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
+	"github.com/joetifa2003/windlang/bytecode"
 	"github.com/joetifa2003/windlang/compiler"
 	"github.com/joetifa2003/windlang/lexer"
 	"github.com/joetifa2003/windlang/parser"
@@ -14,11 +17,12 @@ import (
 
 var (
 	debug = false
+	trace = false
 )
 
 var vmCommand = &cobra.Command{
 	Use:   "vm [file]",
-	Short: "Run a Wind script using vm",
+	Short: "Run a Wind script or a compiled .windc file using vm",
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return fmt.Errorf("requires 1 argument")
@@ -27,7 +31,8 @@ var vmCommand = &cobra.Command{
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		filePath := args[0]
+		// cleaned so the includes that resolve back to the file use the same path
+		filePath := filepath.Clean(args[0])
 
 		file, err := os.ReadFile(filePath)
 		if err != nil {
@@ -36,28 +41,74 @@ var vmCommand = &cobra.Command{
 		}
 
 		// defer profile.Start().Stop()
-		input := string(file)
-		lexer := lexer.New(input)
-		parser := parser.New(lexer, filePath)
-		program := parser.ParseProgram()
-		parserErrors := parser.ReportErrors()
-		if len(parserErrors) > 0 {
-			for _, err := range parserErrors {
-				fmt.Println(err)
+		var program *bytecode.Program
+		if bytecode.IsBytecode(file) {
+			program, err = bytecode.Decode(bytes.NewReader(file))
+			if err != nil {
+				log.Fatalln("Could not load bytecode:", err)
 			}
+		} else {
+			program = compileFile(filePath, string(file))
+		}
 
-			os.Exit(1)
+		if debug {
+			bytecode.Disassemble(os.Stdout, program)
+			fmt.Println()
+		}
+
+		virtualM := vm.NewVM(program.Constants)
+		virtualM.SetPermissions(permissions(filePath))
+		if trace {
+			virtualM.Trace = os.Stdout
 		}
 
-		compiler := compiler.NewCompiler()
-		instructions := compiler.Compile(program)
+		if err := virtualM.Interpret(program.Main); err != nil {
+			fmt.Println(err)
+			if rtErr, ok := err.(*vm.RuntimeError); ok {
+				fmt.Println(rtErr.StackTrace())
+			}
 
-		virtualM := vm.NewVM(compiler.Constants)
-		virtualM.Interpret(instructions)
+			os.Exit(1)
+		}
 	},
 }
 
+// compileFile compiles the source of a script, it exits printing the errors if there are any
+func compileFile(filePath string, input string) *bytecode.Program {
+	lexer := lexer.New(input)
+	parser := parser.New(lexer, filePath)
+	program := parser.ParseProgram()
+	parserErrors := parser.ReportErrors()
+	if len(parserErrors) > 0 {
+		for _, err := range parserErrors {
+			fmt.Println(err)
+		}
+
+		os.Exit(1)
+	}
+
+	compiler := compiler.NewCompiler(filePath)
+	compiler.SetPermissions(permissions(filePath))
+	main := compiler.Compile(program)
+	compilerErrors := compiler.ReportErrors()
+	if len(compilerErrors) > 0 {
+		for _, err := range compilerErrors {
+			fmt.Println(err)
+		}
+
+		os.Exit(1)
+	}
+
+	return &bytecode.Program{
+		SourceFile: filePath,
+		Constants:  compiler.Constants,
+		Main:       main,
+	}
+}
+
 func init() {
-	vmCommand.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Show debug info")
+	vmCommand.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Print the disassembled bytecode before running it")
+	vmCommand.PersistentFlags().BoolVar(&trace, "trace", false, "Print every instruction with the value stack and the environments as it runs")
+	addPermissionFlags(vmCommand)
 	rootCmd.AddCommand(vmCommand)
 }
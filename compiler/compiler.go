@@ -1,21 +1,133 @@
 package compiler
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/joetifa2003/windlang/ast"
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/loader"
 	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
+	"github.com/joetifa2003/windlang/token"
 	"github.com/joetifa2003/windlang/value"
 )
 
+// stdlibModules are the libraries the vm provides, they are included by name instead of by path
+var stdlibModules = map[string]bool{
+	"math":    true,
+	"request": true,
+	"fs":      true,
+}
+
 type Compiler struct {
-	Scopes    [][]string
-	Constants []value.Value
+	Constants   []value.Value
+	Errors      []CompilerError
+	fn          *function            // the function being compiled
+	filePath    string               // the file being compiled
+	line        int                  // the source line of the node being compiled
+	modules     map[string]*module   // the compiled file modules by their path
+	including   []loader.Include     // the include statements being compiled, most recent last
+	permissions *sandbox.Permissions // the files that can be included, the vm checks the std library modules
+	loader      loader.ModuleLoader  // finds and reads the included files
+}
+
+// module is a compiled file
+type module struct {
+	path    string
+	index   int             // the constant index of the module function
+	names   map[string]bool // the top level variables of the file
+	exports map[string]bool // the variables declared with export, nil if every variable is exported
+}
+
+type CompilerError struct {
+	File string
+	Span token.Span
+	Msg  string
+}
+
+// function holds the state of a function being compiled, the main program and included files are functions too
+type function struct {
+	enclosing    *function
+	instructions []opcode.OpCode
+	lines        []int
+	scopes       [][]variable
+	upvalues     []value.Upvalue
+	loops        []loop     // the loops being compiled
+	tries        []tryBlock // the try statements whose handler is active while compiling
+}
+
+type variable struct {
+	name     string
+	constant bool
+	module   *module // the file module the variable is the alias of, its exports are checked when compiling
 }
 
-func NewCompiler() Compiler {
+type loop struct {
+	scopeDepth int   // the number of scopes opened outside of the loop
+	tryDepth   int   // the number of tries active outside of the loop
+	breaks     []int // the jumps to patch to the end of the loop
+	continues  []int // the jumps to patch to the next iteration
+}
+
+type tryBlock struct {
+	scopeDepth int                 // the number of scopes opened outside of the try statement
+	finally    *ast.BlockStatement // nil if there is no finally block
+}
+
+// SetLoader changes where the included files are read from, they are read from the disk by default
+func (c *Compiler) SetLoader(l loader.ModuleLoader) {
+	c.loader = l
+}
+
+// SetPermissions restricts the files the program can include, nil allows all of them
+func (c *Compiler) SetPermissions(permissions *sandbox.Permissions) {
+	c.permissions = permissions
+}
+
+func NewCompiler(filePath string) Compiler {
 	return Compiler{
-		Scopes:    [][]string{},
 		Constants: []value.Value{},
+		Errors:    []CompilerError{},
+		filePath:  filePath,
+		modules:   map[string]*module{},
+		loader:    loader.NewOS(),
+	}
+}
+
+// Compile compiles the program to the main function, the variables of the program live in a block
+func (c *Compiler) Compile(program *ast.Program) *value.Function {
+	c.fn = &function{}
+
+	block := c.beginBlock()
+	c.compileStatements(program.Statements)
+	c.endBlock(block)
+
+	return c.endFunction("<main>", 0, 0)
+}
+
+func (c *Compiler) ReportErrors() []string {
+	errors := []string{}
+	for _, e := range c.Errors {
+		errors = append(
+			errors,
+			fmt.Sprintf(
+				"[file %s:%d:%d]: %s",
+				e.File, e.Span.Start.Line, e.Span.Start.Column, e.Msg,
+			),
+		)
 	}
+
+	return errors
+}
+
+func (c *Compiler) newError(node ast.Node, format string, a ...interface{}) {
+	c.Errors = append(c.Errors, CompilerError{
+		File: c.filePath,
+		Span: node.Span(),
+		Msg:  fmt.Sprintf(format, a...),
+	})
 }
 
 func (c *Compiler) addConstant(v value.Value) int {
@@ -24,269 +136,1016 @@ func (c *Compiler) addConstant(v value.Value) int {
 	return len(c.Constants) - 1
 }
 
+// emit appends an instruction to the current function and returns its position
+func (c *Compiler) emit(op opcode.OpCode, operands ...int) int {
+	position := len(c.fn.instructions)
+
+	c.fn.instructions = append(c.fn.instructions, op)
+	c.fn.lines = append(c.fn.lines, c.line)
+	for _, operand := range operands {
+		c.fn.instructions = append(c.fn.instructions, opcode.OpCode(operand))
+		c.fn.lines = append(c.fn.lines, c.line)
+	}
+
+	return position
+}
+
+func (c *Compiler) emitConstant(v value.Value) {
+	c.emit(opcode.OP_CONST, c.addConstant(v))
+}
+
+// emitJump emits a jump with a placeholder offset and returns the position of the offset
+func (c *Compiler) emitJump(op opcode.OpCode) int {
+	return c.emit(op, 0) + 1
+}
+
+// emitJumpTo emits a jump to an instruction that is already emitted
+func (c *Compiler) emitJumpTo(op opcode.OpCode, target int) {
+	c.patchJumpTo(c.emitJump(op), target)
+}
+
+// patchJump makes the jump land on the next instruction to be emitted
+func (c *Compiler) patchJump(offset int) {
+	c.patchJumpTo(offset, len(c.fn.instructions))
+}
+
+// patchJumpTo sets the offset of a jump, offsets are relative to the position of the offset itself
+func (c *Compiler) patchJumpTo(offset int, target int) {
+	c.fn.instructions[offset] = opcode.OpCode(target - offset)
+}
+
+func (c *Compiler) beginFunction() {
+	c.fn = &function{enclosing: c.fn}
+}
+
+func (c *Compiler) endFunction(name string, arity int, localCount int) *value.Function {
+	fn := c.fn
+	c.fn = fn.enclosing
+
+	return &value.Function{
+		Name:         name,
+		File:         c.filePath,
+		Arity:        arity,
+		LocalCount:   localCount,
+		Instructions: fn.instructions,
+		Lines:        fn.lines,
+		Upvalues:     fn.upvalues,
+	}
+}
+
 func (c *Compiler) beginScope() {
-	c.Scopes = append(c.Scopes, []string{})
+	c.fn.scopes = append(c.fn.scopes, []variable{})
 }
 
-func (c *Compiler) endScope() []string {
-	lastScope := c.Scopes[len(c.Scopes)-1]
-	c.Scopes = c.Scopes[:len(c.Scopes)-1]
+func (c *Compiler) endScope() []variable {
+	lastScope := c.fn.scopes[len(c.fn.scopes)-1]
+	c.fn.scopes = c.fn.scopes[:len(c.fn.scopes)-1]
 
 	return lastScope
 }
 
-func (c *Compiler) addToScope(name string) int {
-	scope := &c.Scopes[len(c.Scopes)-1]
-	*scope = append(*scope, name)
+// addToScope adds a variable to the innermost scope, declaring a variable twice reuses its slot
+func (c *Compiler) addToScope(name string, constant bool) int {
+	scope := &c.fn.scopes[len(c.fn.scopes)-1]
+	for index, v := range *scope {
+		if v.name == name {
+			(*scope)[index] = variable{name: name, constant: constant}
+			return index
+		}
+	}
+
+	*scope = append(*scope, variable{name: name, constant: constant})
 
 	return len(*scope) - 1
 }
 
-// returns scope index and the value index inside it
-func (c *Compiler) findInScope(name string) (int, int) {
-	for scopesIndex := len(c.Scopes) - 1; scopesIndex >= 0; scopesIndex-- {
-		for valueIndex, v := range c.Scopes[scopesIndex] {
-			if v == name {
-				return scopesIndex, valueIndex
+// hoist declares the functions of a block before compiling it,
+// so functions can call the ones declared after them
+func (c *Compiler) hoist(statements []ast.Statement) {
+	for _, stmt := range statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				c.addToScope(let.Name.Value, let.Constant)
+			}
+		}
+	}
+}
+
+// findInScope returns the scope index and the value index inside it,
+// scope indexes are relative to the scope of the function
+func findInScope(fn *function, name string) (scopeIndex int, valueIndex int, constant bool, ok bool) {
+	for scopeIndex := len(fn.scopes) - 1; scopeIndex >= 0; scopeIndex-- {
+		for valueIndex, v := range fn.scopes[scopeIndex] {
+			if v.name == name {
+				return scopeIndex, valueIndex, v.constant, true
 			}
 		}
 	}
 
-	panic("")
+	return 0, 0, false, false
 }
 
-func (c *Compiler) Compile(node ast.Node) []opcode.OpCode {
-	switch node := node.(type) {
-	case *ast.Program:
-		var instructions []opcode.OpCode
+// findUpvalue returns the index of the upvalue capturing the variable from the enclosing functions
+func findUpvalue(fn *function, name string) (index int, constant bool, ok bool) {
+	if fn.enclosing == nil {
+		return 0, false, false
+	}
+
+	upvalue := value.Upvalue{}
+	if scopeIndex, valueIndex, constant, ok := findInScope(fn.enclosing, name); ok {
+		upvalue = value.Upvalue{Local: true, Scope: scopeIndex, Index: valueIndex}
+		return addUpvalue(fn, upvalue), constant, true
+	}
+
+	enclosingIndex, constant, ok := findUpvalue(fn.enclosing, name)
+	if !ok {
+		return 0, false, false
+	}
+
+	upvalue = value.Upvalue{Local: false, Index: enclosingIndex}
+	return addUpvalue(fn, upvalue), constant, true
+}
+
+func addUpvalue(fn *function, upvalue value.Upvalue) int {
+	for index, u := range fn.upvalues {
+		if u == upvalue {
+			return index
+		}
+	}
+
+	fn.upvalues = append(fn.upvalues, upvalue)
+
+	return len(fn.upvalues) - 1
+}
+
+func (c *Compiler) beginLoop() {
+	c.fn.loops = append(c.fn.loops, loop{scopeDepth: len(c.fn.scopes), tryDepth: len(c.fn.tries)})
+}
+
+func (c *Compiler) endLoop() loop {
+	lastLoop := c.fn.loops[len(c.fn.loops)-1]
+	c.fn.loops = c.fn.loops[:len(c.fn.loops)-1]
+
+	return lastLoop
+}
+
+func (c *Compiler) beginTry(finally *ast.BlockStatement) {
+	c.fn.tries = append(c.fn.tries, tryBlock{scopeDepth: len(c.fn.scopes), finally: finally})
+}
+
+func (c *Compiler) endTry() {
+	c.fn.tries = c.fn.tries[:len(c.fn.tries)-1]
+}
+
+// unwindTries closes the try handlers above tryDepth running their finally blocks on the way,
+// it returns the number of scopes still open
+func (c *Compiler) unwindTries(tryDepth int) int {
+	depth := len(c.fn.scopes)
+
+	for i := len(c.fn.tries) - 1; i >= tryDepth; i-- {
+		try := c.fn.tries[i]
+
+		for ; depth > try.scopeDepth; depth-- {
+			c.emit(opcode.OP_END_BLOCK)
+		}
+
+		c.emit(opcode.OP_END_TRY)
+
+		if try.finally != nil {
+			c.compileFinally(try, i)
+		}
+	}
+
+	return depth
+}
+
+// compileLoopExit closes the blocks and the try handlers opened inside the current loop,
+// running the finally blocks on the way, and emits a jump that is patched once the loop is compiled
+func (c *Compiler) compileLoopExit(isBreak bool) {
+	loopIndex := len(c.fn.loops) - 1
+	currentLoop := c.fn.loops[loopIndex]
+
+	depth := c.unwindTries(currentLoop.tryDepth)
+	for ; depth > currentLoop.scopeDepth; depth-- {
+		c.emit(opcode.OP_END_BLOCK)
+	}
+
+	jump := c.emitJump(opcode.OP_JUMP)
+	if isBreak {
+		c.fn.loops[loopIndex].breaks = append(c.fn.loops[loopIndex].breaks, jump)
+	} else {
+		c.fn.loops[loopIndex].continues = append(c.fn.loops[loopIndex].continues, jump)
+	}
+}
+
+// compileFinally compiles the finally block of the try at the given index
+// as if the code was leaving it, with only the scopes and tries outside of it
+func (c *Compiler) compileFinally(try tryBlock, index int) {
+	scopes, tries := c.fn.scopes, c.fn.tries
+
+	c.fn.scopes = append([][]variable{}, scopes[:try.scopeDepth]...)
+	c.fn.tries = append([]tryBlock{}, tries[:index]...)
+	c.compile(try.finally)
+	c.fn.scopes, c.fn.tries = scopes, tries
+}
+
+// compileTry lays out a try statement as follows, the handler runs with the thrown value on the stack
+//
+//	OP_TRY handler
+//	body
+//	OP_END_TRY
+//	OP_JUMP finally
+//	handler: catch, or finally followed by OP_THROW if there is no catch
+//	finally: finally
+func (c *Compiler) compileTry(node *ast.TryStatement) {
+	handler := c.emitJump(opcode.OP_TRY)
+	c.beginTry(node.Finally)
+	c.compile(node.Body)
+	c.endTry()
+	c.emit(opcode.OP_END_TRY)
+	finally := c.emitJump(opcode.OP_JUMP)
+
+	c.patchJump(handler)
+	switch {
+	case node.Catch != nil && node.Finally != nil:
+		// the finally block still runs if the catch block throws
+		rethrow := c.emitJump(opcode.OP_TRY)
+		c.beginTry(node.Finally)
+		c.compileCatch(node)
+		c.endTry()
+		c.emit(opcode.OP_END_TRY)
+		skip := c.emitJump(opcode.OP_JUMP)
+
+		c.patchJump(rethrow)
+		c.compile(node.Finally)
+		c.emit(opcode.OP_THROW)
+		c.patchJump(skip)
+
+	case node.Catch != nil:
+		c.compileCatch(node)
+
+	default:
+		c.compile(node.Finally)
+		c.emit(opcode.OP_THROW)
+	}
+
+	c.patchJump(finally)
+	if node.Finally != nil {
+		c.compile(node.Finally)
+	}
+}
+
+// compileCatch binds the thrown value on the stack to the catch parameter and runs the catch block
+func (c *Compiler) compileCatch(node *ast.TryStatement) {
+	if node.CatchParam == nil {
+		c.emit(opcode.OP_POP)
+		c.compile(node.Catch)
+
+		return
+	}
+
+	block := c.beginBlock()
+	c.emit(opcode.OP_LET, c.addToScope(node.CatchParam.Value, false))
+	c.compile(node.Catch)
+	c.endBlock(block)
+}
+
+// beginBlock opens a scope and emits the block holding its variables at runtime
+func (c *Compiler) beginBlock() int {
+	c.beginScope()
+
+	return c.emit(opcode.OP_BLOCK, 0)
+}
+
+// endBlock closes the scope opened by beginBlock and sets the variable count of the block
+func (c *Compiler) endBlock(block int) {
+	scope := c.endScope()
+	c.fn.instructions[block+1] = opcode.OpCode(len(scope))
+	c.emit(opcode.OP_END_BLOCK)
+}
+
+// compileBlock compiles the statements of a block in a new scope if it declares variables
+func (c *Compiler) compileBlock(node *ast.BlockStatement) {
+	if node.VarCount == 0 {
+		c.compileStatements(node.Statements)
+		return
+	}
+
+	block := c.beginBlock()
+	c.compileStatements(node.Statements)
+	c.endBlock(block)
+}
+
+func (c *Compiler) compileStatements(statements []ast.Statement) {
+	c.hoist(statements)
+	for _, stmt := range statements {
+		c.compile(stmt)
+	}
+}
+
+// compileBranch compiles a branch of an if expression, leaving the value of its last expression on the stack
+func (c *Compiler) compileBranch(node ast.Statement) {
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		c.compileValueStatements([]ast.Statement{node})
+		return
+	}
+
+	if block.VarCount == 0 {
+		c.compileValueStatements(block.Statements)
+		return
+	}
+
+	scopeBlock := c.beginBlock()
+	c.compileValueStatements(block.Statements)
+	c.endBlock(scopeBlock)
+}
+
+// compileValueStatements compiles statements leaving the value of the last one on the stack,
+// nil if it's not an expression
+func (c *Compiler) compileValueStatements(statements []ast.Statement) {
+	c.hoist(statements)
+	for i, stmt := range statements {
+		if expr, ok := stmt.(*ast.ExpressionStatement); ok && i == len(statements)-1 {
+			c.compile(expr.Expression)
+			return
+		}
+
+		c.compile(stmt)
+	}
+
+	c.emitConstant(value.NewNilValue())
+}
+
+// compileFunction compiles the function to a constant and emits the closure creation,
+// the body of a function returns the value of its last expression like in the evaluator
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral, name string) {
+	c.beginFunction()
+	c.beginScope()
+	for _, param := range node.Parameters {
+		c.addToScope(param.Value, false)
+	}
+
+	c.compileValueStatements(node.Body.Statements)
+	c.emit(opcode.OP_RETURN)
+
+	scope := c.endScope()
+	fn := c.endFunction(name, len(node.Parameters), len(scope))
+
+	c.emit(opcode.OP_CLOSURE, c.addConstant(value.NewFunctionValue(&value.Closure{Fn: fn})))
+}
+
+// compileInclude compiles an included file to a module function that returns its exported variables as a hash,
+// each file is compiled once and doesn't see the variables of the file including it.
+// The module is nil for the std library modules, the compiler doesn't know their variables
+func (c *Compiler) compileInclude(node *ast.IncludeStatement) *module {
+	if stdlibModules[node.Path] {
+		c.emit(opcode.OP_INCLUDE, c.addConstant(value.NewStringValue(node.Path)))
+		return nil
+	}
+
+	path, resolveErr := c.loader.Resolve(c.filePath, node.Path)
+	if resolveErr != nil {
+		c.newError(node, "cannot read file: %s", node.Path)
+		return nil
+	}
+
+	if mod, ok := c.modules[path]; ok {
+		c.emit(opcode.OP_INCLUDE, mod.index)
+		return mod
+	}
+
+	include := loader.Include{File: c.filePath, Line: node.Span().Start.Line}
+	if chain, ok := loader.Cycle(append(c.including, include), path); ok {
+		c.newError(node, "circular include: %s", chain)
+		return nil
+	}
+
+	if err := c.permissions.CheckInclude(path); err != nil {
+		c.newError(node, "%s", err.Error())
+		return nil
+	}
+
+	file, loadErr := c.loader.Load(path)
+	if loadErr != nil {
+		c.newError(node, "cannot read file: %s", path)
+		return nil
+	}
+
+	lexer := lexer.New(file)
+	parser := parser.New(lexer, path)
+	program := parser.ParseProgram()
+	if len(parser.Errors) != 0 {
+		for _, e := range parser.Errors {
+			c.Errors = append(c.Errors, CompilerError{File: path, Span: e.Token.Span, Msg: e.Msg})
+		}
+
+		return nil
+	}
+
+	mod := &module{path: path, names: map[string]bool{}}
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Exported {
+			if mod.exports == nil {
+				mod.exports = map[string]bool{}
+			}
+
+			mod.exports[let.Name.Value] = true
+		}
+	}
+
+	enclosing, filePath, line := c.fn, c.filePath, c.line
+	c.fn, c.filePath = &function{}, path
+	c.including = append(c.including, include)
+
+	c.beginScope()
+	c.compileStatements(program.Statements)
+
+	c.emit(opcode.OP_HASH)
+	for index, v := range c.fn.scopes[0] {
+		mod.names[v.name] = true
+		if !mod.exported(v.name) {
+			continue
+		}
 
-		c.beginScope()
-		programInstructions := append(instructions, c.CompileProgram(node.Statements)...)
-		scope := c.endScope()
+		c.emitConstant(value.NewStringValue(v.name))
+		c.emit(opcode.OP_GET, index, 0)
+		c.emit(opcode.OP_HASH_SET)
+	}
+	c.emit(opcode.OP_RETURN)
+
+	scope := c.endScope()
+	fn := c.endFunction("<include "+path+">", 0, len(scope))
+
+	c.including = c.including[:len(c.including)-1]
+	c.fn, c.filePath, c.line = enclosing, filePath, line
+
+	mod.index = c.addConstant(value.NewFunctionValue(&value.Closure{Fn: fn}))
+	c.modules[path] = mod
+	c.emit(opcode.OP_INCLUDE, mod.index)
+
+	return mod
+}
+
+// exported reports whether the files including the module can use the variable
+func (m *module) exported(name string) bool {
+	return m.exports == nil || m.exports[name]
+}
+
+// exportError returns the error of reading a variable of the alias of a file module that the module doesn't export,
+// ok is false if the expression isn't reading the alias of a file module or if the variable can be read
+func (c *Compiler) exportError(node *ast.IndexExpression) (message string, ok bool) {
+	ident, ok := node.Left.(*ast.Identifier)
+	if !ok {
+		return "", false
+	}
+
+	name, ok := node.Index.(*ast.StringLiteral)
+	if !ok {
+		return "", false
+	}
+
+	scopeIndex, valueIndex, _, ok := findInScope(c.fn, ident.Value)
+	if !ok {
+		return "", false
+	}
+
+	mod := c.fn.scopes[scopeIndex][valueIndex].module
+	if mod == nil {
+		return "", false
+	}
+
+	return mod.exportError(name.Value)
+}
+
+// exportError returns the error of reading a variable the module doesn't export, like the evaluator does
+func (m *module) exportError(name string) (message string, ok bool) {
+	if !m.names[name] {
+		return fmt.Sprintf("include key not found: %s", name), true
+	}
+
+	if !m.exported(name) {
+		return fmt.Sprintf("%s is not exported by %s", name, m.path), true
+	}
+
+	return "", false
+}
+
+// compileImports declares the variables imported from the module on the stack. Importing a variable that
+// a file module doesn't export fails when it runs, like in the evaluator, so it can be caught
+func (c *Compiler) compileImports(node *ast.IncludeStatement, mod *module) {
+	for _, imp := range node.Imports {
+		c.emit(opcode.OP_DUP)
+
+		if mod != nil {
+			if message, ok := mod.exportError(imp.Name.Value); ok {
+				c.emit(opcode.OP_ERROR, c.addConstant(value.NewStringValue(message)))
+			}
+		}
+
+		c.emit(opcode.OP_EXPORT, c.addConstant(value.NewStringValue(imp.Name.Value)))
+		c.emit(opcode.OP_LET, c.addToScope(imp.Alias.Value, false))
+	}
+
+	c.emit(opcode.OP_POP)
+}
+
+func (c *Compiler) compileIdentifier(node *ast.Identifier) {
+	if scopeIndex, valueIndex, _, ok := findInScope(c.fn, node.Value); ok {
+		c.emit(opcode.OP_GET, valueIndex, scopeIndex)
+		return
+	}
+
+	if index, _, ok := findUpvalue(c.fn, node.Value); ok {
+		c.emit(opcode.OP_GET_UPVALUE, index)
+		return
+	}
+
+	if node.Value == "this" {
+		c.emit(opcode.OP_THIS)
+		return
+	}
+
+	c.emit(opcode.OP_GET_BUILTIN, c.addConstant(value.NewStringValue(node.Value)))
+}
+
+// compileSetIdentifier stores the value on the stack in the variable and leaves it on the stack
+func (c *Compiler) compileSetIdentifier(node ast.Node, name string) {
+	if scopeIndex, valueIndex, constant, ok := findInScope(c.fn, name); ok {
+		if constant {
+			c.newError(node, "cannot assign to a constant variable %s", name)
+		}
+
+		c.emit(opcode.OP_SET, valueIndex, scopeIndex)
+		return
+	}
+
+	if index, constant, ok := findUpvalue(c.fn, name); ok {
+		if constant {
+			c.newError(node, "cannot assign to a constant variable %s", name)
+		}
+
+		c.emit(opcode.OP_SET_UPVALUE, index)
+		return
+	}
+
+	c.newError(node, "identifier not found: %s", name)
+}
+
+// arithmeticOps are the opcodes of the operators that have a compound assignment
+var arithmeticOps = map[string]opcode.OpCode{
+	"+": opcode.OP_ADD,
+	"-": opcode.OP_SUBTRACT,
+	"*": opcode.OP_MULTIPLY,
+	"/": opcode.OP_DIVIDE,
+	"%": opcode.OP_MODULO,
+}
+
+func (c *Compiler) compileAssign(node *ast.AssignExpression) {
+	if node.Operator != "" {
+		c.compileUpdate(node, node.Name, func() {
+			c.compile(node.Value)
+			c.emit(arithmeticOps[node.Operator])
+		})
+
+		return
+	}
+
+	switch left := node.Name.(type) {
+	case *ast.Identifier:
+		c.compile(node.Value)
+		c.compileSetIdentifier(node, left.Value)
+
+	case *ast.IndexExpression:
+		c.compile(left.Left)
+		c.compile(left.Index)
+		c.compile(node.Value)
+		c.emit(opcode.OP_SET_INDEX)
+
+	default:
+		c.newError(node, "cannot assign to %s", node.Name.String())
+	}
+}
+
+// compileUpdate reads the target, a variable or an index, emits update to turn its value into the new one,
+// then stores the new value in the target and leaves it on the stack
+func (c *Compiler) compileUpdate(node ast.Node, target ast.Expression, update func()) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		c.compile(target)
+		update()
+		c.compileSetIdentifier(node, target.Value)
+
+	case *ast.IndexExpression:
+		c.compile(target.Left)
+		c.compile(target.Index)
+		c.emit(opcode.OP_DUP2)
+		c.emit(opcode.OP_INDEX)
+		update()
+		c.emit(opcode.OP_SET_INDEX)
+
+	default:
+		c.newError(node, "cannot assign to %s", target.String())
+	}
+}
+
+// compilePostfix stores the new value and leaves the old one on the stack
+func (c *Compiler) compilePostfix(node *ast.PostfixExpression) {
+	op := opcode.OP_ADD
+	undo := opcode.OP_SUBTRACT
+	if node.Operator == "--" {
+		op, undo = undo, op
+	}
+
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		scopeIndex, valueIndex, constant, ok := findInScope(c.fn, left.Value)
+		if ok && !constant && node.Operator == "++" {
+			c.emit(opcode.OP_INC, valueIndex, scopeIndex)
+			return
+		}
+
+	case *ast.IndexExpression:
+
+	default:
+		c.newError(node, "postfix operator not supported: %s", node.Left.String())
+		return
+	}
+
+	c.compileUpdate(node, node.Left, func() {
+		c.emitConstant(value.NewIntValue(1))
+		c.emit(op)
+	})
+
+	c.emitConstant(value.NewIntValue(1))
+	c.emit(undo)
+}
+
+// compileForIn lays out a for-in loop as follows, the names starting with $ are hidden variables
+// of a block around the loop and the loop variables are declared in a new block on each iteration
+//
+//	$iterable = iterable, $steps = OP_ITER $iterable, $i = 0
+//	start: OP_NEXT $iterable $steps $i exit
+//	declare the key and the value, body
+//	continue: $i++, jump start
+//	exit:
+//
+// A loop with only a value passes nil as the iterable to OP_NEXT so the value is the step itself, the key of a hash
+func (c *Compiler) compileForIn(node *ast.ForInStatement) {
+	block := c.beginBlock()
+
+	// the hidden variables are in the innermost scope while the loop is compiled outside of its body
+	scope := len(c.fn.scopes) - 1
+	get := func(index int) {
+		c.emit(opcode.OP_GET, index, scope)
+	}
+
+	iterable, steps := c.addToScope("$iterable", false), c.addToScope("$steps", false)
+	i := c.addToScope("$i", false)
+
+	c.compile(node.Iterable)
+	if node.Key == nil {
+		c.emit(opcode.OP_ITER)
+		c.emit(opcode.OP_LET, steps)
+		c.emitConstant(value.NewNilValue())
+		c.emit(opcode.OP_LET, iterable)
+	} else {
+		c.emit(opcode.OP_DUP)
+		c.emit(opcode.OP_LET, iterable)
+		c.emit(opcode.OP_ITER)
+		c.emit(opcode.OP_LET, steps)
+	}
+	c.emitConstant(value.NewIntValue(0))
+	c.emit(opcode.OP_LET, i)
 
-		instructions = append(instructions, opcode.OP_BLOCK)
-		instructions = append(instructions, opcode.OpCode(len(scope)))
-		instructions = append(instructions, programInstructions...)
-		instructions = append(instructions, opcode.OP_END_BLOCK)
+	start := len(c.fn.instructions)
+	get(iterable)
+	get(steps)
+	get(i)
+	exit := c.emitJump(opcode.OP_NEXT)
 
-		return instructions
+	c.beginLoop()
 
+	iteration := c.beginBlock()
+	c.emit(opcode.OP_LET, c.addToScope(node.Value.Value, false))
+	if node.Key != nil {
+		c.emit(opcode.OP_LET, c.addToScope(node.Key.Value, false))
+	} else {
+		c.emit(opcode.OP_POP)
+	}
+
+	c.compile(node.Body)
+	c.endBlock(iteration)
+
+	currentLoop := c.endLoop()
+
+	for _, jump := range currentLoop.continues {
+		c.patchJump(jump)
+	}
+	c.emit(opcode.OP_INC, i, scope)
+	c.emit(opcode.OP_POP)
+	c.emitJumpTo(opcode.OP_JUMP, start)
+
+	c.patchJump(exit)
+	for _, jump := range currentLoop.breaks {
+		c.patchJump(jump)
+	}
+
+	c.endBlock(block)
+}
+
+func (c *Compiler) compileHash(node *ast.HashLiteral) {
+	keys := make([]ast.Expression, 0, len(node.Pairs))
+	for key := range node.Pairs {
+		keys = append(keys, key)
+	}
+
+	// the pairs are compiled in source order so the output doesn't depend on the map order
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Span().Start.Offset < keys[j].Span().Start.Offset
+	})
+
+	c.emit(opcode.OP_HASH)
+	for _, key := range keys {
+		c.compile(key)
+
+		fn, isFunction := node.Pairs[key].(*ast.FunctionLiteral)
+		if !isFunction {
+			c.compile(node.Pairs[key])
+			c.emit(opcode.OP_HASH_SET)
+			continue
+		}
+
+		name := "<anonymous>"
+		if str, ok := key.(*ast.StringLiteral); ok {
+			name = str.Value
+		}
+
+		c.compileFunction(fn, name)
+		c.emit(opcode.OP_HASH_METHOD)
+	}
+}
+
+func (c *Compiler) compile(node ast.Node) {
+	line := c.line
+	c.line = node.Span().Start.Line
+	defer func() { c.line = line }()
+
+	switch node := node.(type) {
 	case *ast.ExpressionStatement:
-		expr := c.Compile(node.Expression)
-		expr = append(expr, opcode.OP_POP)
+		c.compile(node.Expression)
+		c.emit(opcode.OP_POP)
 
-		return expr
+	case *ast.PrefixExpression:
+		if node.Operator == "++" || node.Operator == "--" {
+			c.compileUpdate(node, node.Right, func() {
+				c.emitConstant(value.NewIntValue(1))
+				c.emit(arithmeticOps[node.Operator[:1]])
+			})
 
-	case *ast.InfixExpression:
-		var instructions []opcode.OpCode
+			return
+		}
 
-		left := c.Compile(node.Left)
-		right := c.Compile(node.Right)
+		c.compile(node.Right)
 
-		instructions = append(instructions, left...)
-		instructions = append(instructions, right...)
+		switch node.Operator {
+		case "!":
+			c.emit(opcode.OP_NOT)
+		case "-":
+			c.emit(opcode.OP_NEGATE)
+		default:
+			c.newError(node, "unknown operator: %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		c.compile(node.Left)
+		c.compile(node.Right)
 
 		switch node.Operator {
 		case "+":
-			instructions = append(instructions, opcode.OP_ADD)
+			c.emit(opcode.OP_ADD)
 		case "-":
-			instructions = append(instructions, opcode.OP_SUBTRACT)
+			c.emit(opcode.OP_SUBTRACT)
 		case "*":
-			instructions = append(instructions, opcode.OP_MULTIPLY)
+			c.emit(opcode.OP_MULTIPLY)
 		case "/":
-			instructions = append(instructions, opcode.OP_DIVIDE)
-		case "<=":
-			instructions = append(instructions, opcode.OP_LESSEQ)
+			c.emit(opcode.OP_DIVIDE)
 		case "%":
-			instructions = append(instructions, opcode.OP_MODULO)
+			c.emit(opcode.OP_MODULO)
+		case "<":
+			c.emit(opcode.OP_LESS)
+		case "<=":
+			c.emit(opcode.OP_LESSEQ)
+		case ">":
+			c.emit(opcode.OP_GREATER)
+		case ">=":
+			c.emit(opcode.OP_GREATEREQ)
 		case "==":
-			instructions = append(instructions, opcode.OP_EQ)
+			c.emit(opcode.OP_EQ)
+		case "!=":
+			c.emit(opcode.OP_NOTEQ)
+		case "&&":
+			c.emit(opcode.OP_AND)
+		case "||":
+			c.emit(opcode.OP_OR)
 
 		default:
-			panic("Unimplemented operator " + node.Operator)
+			c.newError(node, "unknown operator: %s", node.Operator)
 		}
 
-		return instructions
-
 	case *ast.IfExpression:
-		var instructions []opcode.OpCode
+		c.compile(node.Condition)
+		elseJump := c.emitJump(opcode.OP_JUMP_FALSE)
+		c.compileBranch(node.ThenBranch)
+		endJump := c.emitJump(opcode.OP_JUMP)
 
-		condition := c.Compile(node.Condition)
-		thenBranch := c.Compile(node.ThenBranch)
-		elseBranch := []opcode.OpCode{}
+		c.patchJump(elseJump)
 		if node.ElseBranch != nil {
-			elseBranch = c.Compile(node.ElseBranch)
+			c.compileBranch(node.ElseBranch)
+		} else {
+			c.emitConstant(value.NewNilValue())
 		}
+		c.patchJump(endJump)
+
+	case *ast.IntegerLiteral:
+		c.emitConstant(value.NewIntValue(node.Value))
 
-		instructions = append(instructions, condition...)
-		instructions = append(instructions, opcode.OP_JUMP_FALSE)
-		instructions = append(instructions, opcode.OpCode(len(thenBranch)+3))
-		instructions = append(instructions, thenBranch...)
-		instructions = append(instructions, opcode.OP_JUMP)
-		instructions = append(instructions, opcode.OpCode(len(elseBranch)+1))
-		instructions = append(instructions, elseBranch...)
+	case *ast.FloatLiteral:
+		c.emitConstant(value.NewFloatValue(node.Value))
 
-		return instructions
+	case *ast.StringLiteral:
+		c.emitConstant(value.NewStringValue(node.Value))
 
-	case *ast.IntegerLiteral:
-		return []opcode.OpCode{
-			opcode.OP_CONST,
-			opcode.OpCode(
-				c.addConstant(value.NewIntValue(node.Value)),
-			),
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			c.compile(part)
 		}
 
+		c.emit(opcode.OP_TEMPLATE, len(node.Parts))
+
 	case *ast.Boolean:
-		return []opcode.OpCode{
-			opcode.OP_CONST,
-			opcode.OpCode(
-				c.addConstant(value.NewBoolValue(node.Value)),
-			),
-		}
+		c.emitConstant(value.NewBoolValue(node.Value))
+
+	case *ast.NilLiteral:
+		c.emitConstant(value.NewNilValue())
 
 	case *ast.BlockStatement:
-		var instructions []opcode.OpCode
-		var bodyInstructions []opcode.OpCode
+		c.compileBlock(node)
 
-		if node.VarCount != 0 {
-			c.beginScope()
-			for _, stmt := range node.Statements {
-				bodyInstructions = append(bodyInstructions, c.Compile(stmt)...)
-			}
-			scope := c.endScope()
+	case *ast.WhileStatement:
+		start := len(c.fn.instructions)
+		c.compile(node.Condition)
+		exit := c.emitJump(opcode.OP_JUMP_FALSE)
 
-			instructions = append(instructions, opcode.OP_BLOCK)
-			instructions = append(instructions, opcode.OpCode(len(scope)))
-			instructions = append(instructions, bodyInstructions...)
-			instructions = append(instructions, opcode.OP_END_BLOCK)
+		c.beginLoop()
+		c.compile(node.Body)
+		currentLoop := c.endLoop()
 
-			return instructions
-		} else {
-			for _, stmt := range node.Statements {
-				bodyInstructions = append(bodyInstructions, c.Compile(stmt)...)
-			}
+		for _, jump := range currentLoop.continues {
+			c.patchJumpTo(jump, start)
+		}
+		c.emitJumpTo(opcode.OP_JUMP, start)
 
-			return bodyInstructions
+		c.patchJump(exit)
+		for _, jump := range currentLoop.breaks {
+			c.patchJump(jump)
 		}
 
-	case *ast.WhileStatement:
-		var instructions []opcode.OpCode
+	case *ast.ForStatement:
+		block := c.beginBlock()
+		c.compile(node.Initializer)
 
-		condition := c.Compile(node.Condition)
-		body := c.Compile(node.Body)
+		start := len(c.fn.instructions)
+		c.compile(node.Condition)
+		exit := c.emitJump(opcode.OP_JUMP_FALSE)
 
-		instructions = append(instructions, condition...)
-		instructions = append(instructions, opcode.OP_JUMP_FALSE)
-		instructions = append(instructions, opcode.OpCode(len(body)+3))
-		instructions = append(instructions, body...)
-		instructions = append(instructions, opcode.OP_JUMP)
-		instructions = append(instructions, opcode.OpCode(-len(body)-len(condition)-3))
+		c.beginLoop()
+		c.compile(node.Body)
+		currentLoop := c.endLoop()
 
-		return instructions
+		for _, jump := range currentLoop.continues {
+			c.patchJump(jump)
+		}
+		c.compile(node.Increment)
+		c.emit(opcode.OP_POP)
+		c.emitJumpTo(opcode.OP_JUMP, start)
 
-	case *ast.ForStatement:
-		var bodyInstructions []opcode.OpCode
-		var instructions []opcode.OpCode
-
-		c.beginScope()
-		initializer := c.Compile(node.Initializer)
-		condition := c.Compile(node.Condition)
-		body := c.Compile(node.Body)
-		increment := c.Compile(node.Increment)
-		increment = append(increment, opcode.OP_POP)
-		scope := c.endScope()
-
-		bodyInstructions = append(bodyInstructions, initializer...)
-		bodyInstructions = append(bodyInstructions, condition...)
-		bodyInstructions = append(bodyInstructions, opcode.OP_JUMP_FALSE)
-		bodyInstructions = append(bodyInstructions, opcode.OpCode(len(body)+len(increment)+3))
-		bodyInstructions = append(bodyInstructions, body...)
-		bodyInstructions = append(bodyInstructions, increment...)
-		bodyInstructions = append(bodyInstructions, opcode.OP_JUMP)
-		bodyInstructions = append(bodyInstructions, opcode.OpCode(-len(body)-len(increment)-len(condition)-3))
-
-		instructions = append(instructions, opcode.OP_BLOCK)
-		instructions = append(instructions, opcode.OpCode(len(scope)))
-		instructions = append(instructions, bodyInstructions...)
-		instructions = append(instructions, opcode.OP_END_BLOCK)
-
-		return instructions
+		c.patchJump(exit)
+		for _, jump := range currentLoop.breaks {
+			c.patchJump(jump)
+		}
+
+		c.endBlock(block)
+
+	case *ast.ForInStatement:
+		c.compileForIn(node)
 
 	case *ast.LetStatement:
-		var instructions []opcode.OpCode
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			// the name is declared first so the function can call itself
+			index := c.addToScope(node.Name.Value, node.Constant)
+			c.compileFunction(fn, node.Name.Value)
+			c.emit(opcode.OP_LET, index)
 
-		value := c.Compile(node.Value)
-		index := c.addToScope(node.Name.Value)
-		instructions = append(instructions, value...)
-		instructions = append(instructions, opcode.OP_LET)
-		instructions = append(instructions, opcode.OpCode(index))
+			return
+		}
 
-		return instructions
+		c.compile(node.Value)
+		index := c.addToScope(node.Name.Value, node.Constant)
+		c.emit(opcode.OP_LET, index)
 
 	case *ast.Identifier:
-		scopeIndex, valueIndex := c.findInScope(node.Value)
-		return []opcode.OpCode{
-			opcode.OP_GET,
-			opcode.OpCode(valueIndex),
-			opcode.OpCode(scopeIndex),
-		}
+		c.compileIdentifier(node)
 
 	case *ast.AssignExpression:
-		var instructions []opcode.OpCode
-		scopeIndex, valueIndex := c.findInScope(node.Name.TokenLiteral())
-		value := c.Compile(node.Value)
-		instructions = append(instructions, value...)
-		instructions = append(instructions,
-			opcode.OP_SET,
-			opcode.OpCode(valueIndex),
-			opcode.OpCode(scopeIndex),
-		)
-
-		return instructions
+		c.compileAssign(node)
 
 	case *ast.PostfixExpression:
-		var instructions []opcode.OpCode
-		scopeIndex, valueIndex := c.findInScope(node.Left.TokenLiteral())
-		instructions = append(instructions,
-			opcode.OP_INC,
-			opcode.OpCode(valueIndex),
-			opcode.OpCode(scopeIndex),
-		)
-
-		return instructions
+		c.compilePostfix(node)
 
 	case *ast.EchoStatement:
-		var instructions []opcode.OpCode
+		c.compile(node.Value)
+		c.emit(opcode.OP_ECHO)
 
-		instructions = append(instructions, c.Compile(node.Value)...)
-		instructions = append(instructions, opcode.OP_ECHO)
+	case *ast.TryStatement:
+		c.compileTry(node)
 
-		return instructions
+	case *ast.ThrowStatement:
+		c.compile(node.Value)
+		c.emit(opcode.OP_THROW)
 
-	case *ast.NilLiteral:
-		return []opcode.OpCode{opcode.OP_CONST, opcode.OpCode(c.addConstant(value.NewNilValue()))}
+	case *ast.BreakStatement:
+		c.compileLoopExit(true)
 
-	case *ast.ArrayLiteral:
-		instructions := []opcode.OpCode{}
+	case *ast.ContinueStatement:
+		c.compileLoopExit(false)
 
+	case *ast.ArrayLiteral:
 		for i := len(node.Value) - 1; i >= 0; i-- {
-			instructions = append(instructions, c.Compile(node.Value[i])...)
+			c.compile(node.Value[i])
 		}
 
-		instructions = append(instructions, opcode.OP_ARRAY, opcode.OpCode(len(node.Value)))
+		c.emit(opcode.OP_ARRAY, len(node.Value))
 
-		return instructions
+	case *ast.HashLiteral:
+		c.compileHash(node)
 
-	default:
-		panic("Unimplemented Ast %d")
-	}
-}
+	case *ast.IndexExpression:
+		c.compile(node.Left)
 
-func (c *Compiler) CompileProgram(statements []ast.Statement) []opcode.OpCode {
-	var instructions []opcode.OpCode
-	for _, stmt := range statements {
-		instructions = append(instructions, c.Compile(stmt)...)
-	}
+		if message, ok := c.exportError(node); ok {
+			c.emit(opcode.OP_ERROR, c.addConstant(value.NewStringValue(message)))
+			break
+		}
+
+		c.compile(node.Index)
+		c.emit(opcode.OP_INDEX)
+
+	case *ast.RangeExpression:
+		c.compile(node.Start)
+
+		switch {
+		case node.End == nil:
+			c.emit(opcode.OP_RANGE, int(opcode.RANGE_OPEN))
+		case node.Inclusive:
+			c.compile(node.End)
+			c.emit(opcode.OP_RANGE, int(opcode.RANGE_INCLUSIVE))
+		default:
+			c.compile(node.End)
+			c.emit(opcode.OP_RANGE, int(opcode.RANGE_EXCLUSIVE))
+		}
+
+	case *ast.FunctionLiteral:
+		c.compileFunction(node, "<anonymous>")
 
-	return instructions
+	case *ast.CallExpression:
+		c.compile(node.Function)
+		for _, arg := range node.Arguments {
+			c.compile(arg)
+		}
+
+		c.emit(opcode.OP_CALL, len(node.Arguments))
+
+	case *ast.ReturnStatement:
+		c.compile(node.ReturnValue)
+		c.unwindTries(0)
+		c.emit(opcode.OP_RETURN)
+
+	case *ast.IncludeStatement:
+		mod := c.compileInclude(node)
+
+		switch {
+		case node.Alias != nil:
+			index := c.addToScope(node.Alias.Value, false)
+			c.fn.scopes[len(c.fn.scopes)-1][index].module = mod
+			c.emit(opcode.OP_LET, index)
+		case node.Imports != nil:
+			c.compileImports(node, mod)
+		default:
+			c.emit(opcode.OP_IMPORT)
+		}
+
+	default:
+		c.newError(node, "cannot compile %s", node.TokenLiteral())
+	}
 }
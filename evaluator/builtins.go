@@ -3,7 +3,7 @@ package evaluator
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/joetifa2003/windlang/ast"
 )
@@ -17,7 +17,7 @@ var builtins = map[string]*GoFunction{
 				argsString = append(argsString, arg.Inspect())
 			}
 
-			fmt.Println(argsString...)
+			fmt.Fprintln(evaluator.stdout, argsString...)
 
 			return NIL, nil
 		},
@@ -30,7 +30,7 @@ var builtins = map[string]*GoFunction{
 				argsString = append(argsString, arg.Inspect())
 			}
 
-			fmt.Print(argsString...)
+			fmt.Fprint(evaluator.stdout, argsString...)
 
 			return NIL, nil
 		},
@@ -45,7 +45,7 @@ var builtins = map[string]*GoFunction{
 				return &String{Value: fmt.Sprintf("%f", arg.Value)}, nil
 			}
 
-			return nil, evaluator.newError(node.Token, "argument to `string` not supported")
+			return nil, evaluator.newError(node, "argument to `string` not supported")
 		},
 	},
 	"input": {
@@ -54,15 +54,17 @@ var builtins = map[string]*GoFunction{
 		Fn: func(evaluator *Evaluator, node *ast.CallExpression, args ...Object) (Object, *Error) {
 			if len(args) != 0 {
 				prompt := args[0]
-				fmt.Print(prompt.Inspect())
+				fmt.Fprint(evaluator.stdout, prompt.Inspect())
 			}
 
-			var input string
-			scanner := bufio.NewScanner(os.Stdin)
-			scanner.Scan()
-			input = scanner.Text()
-
-			return &String{Value: input}, nil
+			return &String{Value: readLine(evaluator.stdin)}, nil
 		},
 	},
 }
+
+// readLine reads a line without its line ending, the last line may not end with a new line
+func readLine(reader *bufio.Reader) string {
+	line, _ := reader.ReadString('\n')
+
+	return strings.TrimRight(line, "\r\n")
+}
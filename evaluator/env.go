@@ -6,6 +6,7 @@ type Environment struct {
 	Outer           *Environment
 	Includes        []*Environment
 	IncludesAliased map[string]*IncludeObject
+	Exports         map[string]bool // the variables declared with export, if there are none every variable is exported
 }
 
 func NewEnvironment() *Environment {
@@ -58,7 +59,7 @@ func (e *Environment) getIncludes(name string) (Object, bool) {
 	}
 
 	for _, include := range e.Includes {
-		if obj, ok := include.Store[name]; ok {
+		if obj, ok := include.GetExported(name); ok {
 			return obj, ok
 		}
 	}
@@ -66,6 +67,45 @@ func (e *Environment) getIncludes(name string) (Object, bool) {
 	return nil, false
 }
 
+// Export makes a top level variable of a file visible to the files including it
+func (e *Environment) Export(name string) {
+	if e.Exports == nil {
+		e.Exports = make(map[string]bool)
+	}
+
+	e.Exports[name] = true
+}
+
+// IsExported reports whether the files including this file can use the variable,
+// a file that doesn't export any variable exports all of them
+func (e *Environment) IsExported(name string) bool {
+	return e.Exports == nil || e.Exports[name]
+}
+
+// Declares reports whether the variable is declared by this environment itself,
+// the outer environments and the includes are not searched
+func (e *Environment) Declares(name string) bool {
+	_, constant := e.ConstantStore[name]
+	_, variable := e.Store[name]
+
+	return constant || variable
+}
+
+// GetExported returns a top level variable of an included file if it's exported
+func (e *Environment) GetExported(name string) (Object, bool) {
+	if !e.IsExported(name) {
+		return nil, false
+	}
+
+	if obj, ok := e.ConstantStore[name]; ok {
+		return obj, true
+	}
+
+	obj, ok := e.Store[name]
+
+	return obj, ok
+}
+
 // Set For assigning
 func (e *Environment) Set(name string, val Object) (Object, bool) {
 	if e.Store == nil {
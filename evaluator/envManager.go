@@ -1,13 +1,52 @@
 package evaluator
 
+import "github.com/joetifa2003/windlang/loader"
+
 type EnvironmentManager struct {
 	environments map[string]*Environment // A map of environments for each file
+	sources      map[string]string       // A map of source code for each file, used for error reporting
+	loader       loader.ModuleLoader     // Finds and reads the included files
 }
 
 func NewEnvironmentManager() *EnvironmentManager {
 	return &EnvironmentManager{
 		environments: make(map[string]*Environment),
+		sources:      make(map[string]string),
+		loader:       loader.NewOS(),
+	}
+}
+
+// SetLoader changes where the included files are read from, they are read from the disk by default
+func (em *EnvironmentManager) SetLoader(l loader.ModuleLoader) {
+	em.loader = l
+}
+
+// Resolve returns the path of the file included with path by the file from
+func (em *EnvironmentManager) Resolve(from string, path string) (string, error) {
+	return em.loader.Resolve(from, path)
+}
+
+// Load reads the source of a resolved file and records it for error reporting
+func (em *EnvironmentManager) Load(fileName string) (string, error) {
+	source, err := em.loader.Load(fileName)
+	if err != nil {
+		return "", err
 	}
+
+	em.SetSource(fileName, source)
+
+	return source, nil
+}
+
+// SetSource records the source code of the given file so errors can show the offending line
+func (em *EnvironmentManager) SetSource(fileName string, source string) {
+	em.sources[fileName] = source
+}
+
+// Source returns the source code recorded for the given file
+func (em *EnvironmentManager) Source(fileName string) (string, bool) {
+	source, ok := em.sources[fileName]
+	return source, ok
 }
 
 // Get returns the environment for the given file name. and whither it's evaluated or not
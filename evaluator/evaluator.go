@@ -1,36 +1,91 @@
 package evaluator
 
 import (
+	"bufio"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"math"
+	"os"
+	"strings"
 
 	"github.com/joetifa2003/windlang/ast"
 	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/loader"
 	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
 	"github.com/joetifa2003/windlang/token"
 )
 
 var (
-	NIL   = &Nil{}
-	TRUE  = &Boolean{Value: true}
-	FALSE = &Boolean{Value: false}
+	NIL      = &Nil{}
+	TRUE     = &Boolean{Value: true}
+	FALSE    = &Boolean{Value: false}
+	BREAK    = &Break{}
+	CONTINUE = &Continue{}
 )
 
 type Evaluator struct {
-	envManager *EnvironmentManager
-	filePath   string
+	envManager  *EnvironmentManager
+	filePath    string           // the file of the code being evaluated
+	callStack   []callFrame      // the calls being evaluated, most recent last
+	including   []loader.Include // the include statements being evaluated, most recent last
+	stdout      io.Writer        // where echo and the print builtins write
+	stdin       *bufio.Reader    // where the input builtin reads from
+	budget      *sandbox.Counter
+	permissions *sandbox.Permissions // what the script can access, nil allows everything
+}
+
+// callFrame is a call in progress, file and span are where the call was made from
+type callFrame struct {
+	function string
+	file     string
+	span     token.Span
 }
 
 func New(envManager *EnvironmentManager, filePath string) *Evaluator {
 	return &Evaluator{
 		envManager: envManager,
 		filePath:   filePath,
+		stdout:     os.Stdout,
+		stdin:      bufio.NewReader(os.Stdin),
+		budget:     sandbox.NewCounter(context.Background(), sandbox.Limits{}),
+	}
+}
+
+// SetPermissions restricts the modules, the files and the network the script can access
+func (e *Evaluator) SetPermissions(permissions *sandbox.Permissions) {
+	e.permissions = permissions
+}
+
+// SetLimits stops the evaluation when the context is done or when the script goes over the limits,
+// the error of the evaluation wraps sandbox.ErrCancelled or sandbox.ErrLimitExceeded and can't be caught by the script
+func (e *Evaluator) SetLimits(ctx context.Context, limits sandbox.Limits) {
+	e.budget = sandbox.NewCounter(ctx, limits)
+}
+
+// SetStdout makes the script write its output to w instead of the standard output
+func (e *Evaluator) SetStdout(w io.Writer) {
+	e.stdout = w
+}
+
+// SetStdin makes the script read its input from r instead of the standard input,
+// pass the same *bufio.Reader to evaluators that share the input so they don't lose what the others buffered
+func (e *Evaluator) SetStdin(r io.Reader) {
+	if reader, ok := r.(*bufio.Reader); ok {
+		e.stdin = reader
+		return
 	}
+
+	e.stdin = bufio.NewReader(r)
 }
 
 // Eval returns the result of the evaluation and potential error
 func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object, *Error) {
+	if err := e.budget.Step(); err != nil {
+		return nil, e.newFatalError(node, err)
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return e.evalProgram(node.Statements, env, this)
@@ -53,9 +108,24 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object,
 	case *ast.WhileStatement:
 		return e.evalWhileStatement(node, env, this)
 
+	case *ast.ForInStatement:
+		return e.evalForInStatement(node, env, this)
+
 	case *ast.IncludeStatement:
 		return e.evalIncludeStatement(node, env, this)
 
+	case *ast.TryStatement:
+		return e.evalTryStatement(node, env, this)
+
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env, this)
+
+	case *ast.BreakStatement:
+		return BREAK, nil
+
+	case *ast.ContinueStatement:
+		return CONTINUE, nil
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return Integer{Value: node.Value}, nil
@@ -82,7 +152,7 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object,
 		return e.evalIdentifier(node, env, this)
 
 	case *ast.FunctionLiteral:
-		return &Function{Parameters: node.Parameters, Body: node.Body, Env: env, This: this}, nil
+		return &Function{FilePath: e.filePath, Parameters: node.Parameters, Body: node.Body, Env: env, This: this}, nil
 
 	case *ast.CallExpression:
 		return e.evalCallExpression(node, env, this)
@@ -90,6 +160,9 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object,
 	case *ast.StringLiteral:
 		return &String{Value: node.Value}, nil
 
+	case *ast.TemplateLiteral:
+		return e.evalTemplateLiteral(node, env, this)
+
 	case *ast.AssignExpression:
 		return e.evalAssignExpression(node, env, this)
 
@@ -99,6 +172,9 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object,
 	case *ast.IndexExpression:
 		return e.evalIndexExpression(node, env, this)
 
+	case *ast.RangeExpression:
+		return e.evalRangeExpression(node, env, this)
+
 	case *ast.NilLiteral:
 		return NIL, nil
 
@@ -111,7 +187,7 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment, this Object) (Object,
 			return nil, err
 		}
 
-		fmt.Println(val.Inspect())
+		fmt.Fprintln(e.stdout, val.Inspect())
 	}
 
 	return NIL, nil
@@ -135,11 +211,22 @@ func (e *Evaluator) applyFunction(node *ast.CallExpression, fn Object, args []Ob
 	switch fn := fn.(type) {
 	case *Function:
 		if len(args) != len(fn.Parameters) {
-			return nil, e.newError(node.Token, "expected %d arg(s) got %d", len(fn.Parameters), len(args))
+			return nil, e.newError(node, "expected %d arg(s) got %d", len(fn.Parameters), len(args))
+		}
+
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+
+		if err := e.budget.CallDepth(len(e.callStack) + 1); err != nil {
+			return nil, e.newFatalError(node, err)
 		}
 
 		extendedEnv := e.extendFunctionEnv(fn, args)
+		filePath := e.enterCall(name, node, fn.FilePath)
 		evaluated, err := e.Eval(fn.Body, extendedEnv, fn.This)
+		e.exitCall(filePath)
 		if err != nil {
 			return nil, err
 		}
@@ -148,21 +235,77 @@ func (e *Evaluator) applyFunction(node *ast.CallExpression, fn Object, args []Ob
 
 	case *GoFunction:
 		if fn.ArgsCount != -1 && len(args) != fn.ArgsCount {
-			return nil, e.newError(node.Token, "expected %d arg(s) got %d", fn.ArgsCount, len(args))
+			return nil, e.newError(node, "expected %d arg(s) got %d", fn.ArgsCount, len(args))
 		}
 
 		for i, t := range fn.ArgsTypes {
-			if t != Any && t != args[i].Type() {
-				return nil, e.newError(node.Token, "expected arg %d to be of type %s got %s", i, t, args[i].Type())
+			if i < len(args) && t != Any && t != args[i].Type() {
+				return nil, e.newError(node, "expected arg %d to be of type %s got %s", i, t, args[i].Type())
 			}
 		}
 
-		return fn.Fn(e, node, args...)
+		result, err := fn.Fn(e, node, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return result, e.checkSize(node, result)
 
 	default:
-		return nil, e.newError(node.Token, "not a function: %s", fn.Inspect())
+		return nil, e.newError(node, "not a function: %s", fn.Inspect())
+	}
+
+}
+
+// CallFunction calls a function from go code, the call has no location in the source
+func (e *Evaluator) CallFunction(fn Object, args ...Object) (Object, *Error) {
+	return e.applyFunction(&ast.CallExpression{}, fn, args)
+}
+
+// enterCall records a call made from node and switches to the file of the callee,
+// it returns the file of the caller to be restored by exitCall
+func (e *Evaluator) enterCall(function string, node ast.Node, filePath string) string {
+	e.callStack = append(e.callStack, callFrame{
+		function: function,
+		file:     e.filePath,
+		span:     node.Span(),
+	})
+
+	callerFilePath := e.filePath
+	e.filePath = filePath
+
+	return callerFilePath
+}
+
+func (e *Evaluator) exitCall(callerFilePath string) {
+	e.callStack = e.callStack[:len(e.callStack)-1]
+	e.filePath = callerFilePath
+}
+
+// stackTrace returns the frames leading to the given location, most recent first
+func (e *Evaluator) stackTrace(file string, span token.Span) []StackFrame {
+	frames := make([]StackFrame, 0, len(e.callStack)+1)
+
+	for i := len(e.callStack) - 1; i >= 0; i-- {
+		call := e.callStack[i]
+		frames = append(frames, StackFrame{
+			Function: call.function,
+			File:     file,
+			Line:     span.Start.Line,
+			Column:   span.Start.Column,
+		})
+
+		file, span = call.file, call.span
 	}
 
+	frames = append(frames, StackFrame{
+		Function: "<main>",
+		File:     file,
+		Line:     span.Start.Line,
+		Column:   span.Start.Column,
+	})
+
+	return frames
 }
 
 func (e *Evaluator) extendFunctionEnv(
@@ -192,12 +335,20 @@ func (e *Evaluator) evalLetStatement(node *ast.LetStatement, env *Environment, t
 		return nil, err
 	}
 
+	if fn, ok := val.(*Function); ok && fn.Name == "" {
+		fn.Name = node.Name.Value
+	}
+
 	if node.Constant {
 		env.LetConstant(node.Name.Value, val)
 	} else {
 		env.Let(node.Name.Value, val)
 	}
 
+	if node.Exported {
+		env.Export(node.Name.Value)
+	}
+
 	return NIL, nil
 }
 
@@ -247,7 +398,7 @@ func (e *Evaluator) evalProgram(statements []ast.Statement, env *Environment, th
 func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *Environment, this Object) (Object, *Error) {
 	enclosedEnv := NewEnclosedEnvironment(env)
 
-	var result Object
+	var result Object = NIL
 	var err *Error
 
 	for _, statement := range block.Statements {
@@ -256,7 +407,7 @@ func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *Environme
 			return nil, err
 		}
 
-		if isReturn(result) {
+		if isReturn(result) || isLoopControl(result) {
 			return result, nil
 		}
 	}
@@ -295,6 +446,10 @@ func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *Environment, t
 				return result, nil
 			}
 
+			if result == BREAK {
+				break
+			}
+
 			bodyEnv.ClearStore()
 
 			_, err = e.Eval(node.Increment, enclosedEnv, this)
@@ -323,6 +478,10 @@ func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *Environment, t
 				return result, nil
 			}
 
+			if result == BREAK {
+				break
+			}
+
 			_, err = e.Eval(node.Increment, enclosedEnv, this)
 			if err != nil {
 				return nil, err
@@ -352,28 +511,171 @@ func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *Environmen
 		if isReturn(result) {
 			return result, nil
 		}
+
+		if result == BREAK {
+			break
+		}
 	}
 
 	return NIL, nil
 }
 
+func (e *Evaluator) evalForInStatement(node *ast.ForInStatement, env *Environment, this Object) (Object, *Error) {
+	var result Object = NIL
+	var iterable Object
+
+	// body runs an iteration with the loop variables declared in a new environment,
+	// so closures created in the body capture the values of their iteration
+	body := func(key, value Object) (bool, *Error) {
+		loopEnv := NewEnclosedEnvironment(env)
+		if node.Key != nil {
+			loopEnv.Let(node.Key.Value, key)
+			loopEnv.Let(node.Value.Value, value)
+		} else if _, ok := iterable.(*Hash); ok {
+			// a loop over a hash with one variable steps over its keys
+			loopEnv.Let(node.Value.Value, key)
+		} else {
+			loopEnv.Let(node.Value.Value, value)
+		}
+
+		bodyResult, err := e.Eval(node.Body, loopEnv, this)
+		if err != nil {
+			return false, err
+		}
+
+		if isReturn(bodyResult) {
+			result = bodyResult
+			return false, nil
+		}
+
+		return bodyResult != BREAK, nil
+	}
+
+	iterable, err := e.Eval(node.Iterable, env, this)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.iterate(node.Iterable, iterable, body); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (e *Evaluator) evalRangeExpression(node *ast.RangeExpression, env *Environment, this Object) (Object, *Error) {
+	start, err := e.Eval(node.Start, env, this)
+	if err != nil {
+		return nil, err
+	}
+
+	var end Object
+	if node.End != nil {
+		end, err = e.Eval(node.End, env, this)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := e.newRange(node, start, end, node.Inclusive)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// iterate calls fn with the index and the element of each element of an array, the index and the character
+// of each character of a string, each key and value of a hash in the order of SortedKeys, or the index and
+// the integer of each integer of a range, until fn returns false
+func (e *Evaluator) iterate(node ast.Node, obj Object, fn func(key, value Object) (bool, *Error)) *Error {
+	switch obj := obj.(type) {
+	case *Array:
+		// the length is checked on each iteration as the body can change the array
+		for i := 0; i < len(obj.Value); i++ {
+			if next, err := fn(Integer{Value: i}, obj.Value[i]); err != nil || !next {
+				return err
+			}
+		}
+
+	case *String:
+		for i, ch := range []rune(obj.Value) {
+			if next, err := fn(Integer{Value: i}, &String{Value: string(ch)}); err != nil || !next {
+				return err
+			}
+		}
+
+	case *Hash:
+		for _, key := range obj.SortedKeys() {
+			if next, err := fn(hashKeyObject(key), obj.Pairs[key]); err != nil || !next {
+				return err
+			}
+		}
+
+	case *Range:
+		for i := 0; ; i++ {
+			n, ok := obj.At(i)
+			if !ok {
+				break
+			}
+
+			if next, err := fn(Integer{Value: i}, Integer{Value: n}); err != nil || !next {
+				return err
+			}
+		}
+
+	default:
+		return e.newError(node, "cannot iterate over %s", obj.Type())
+	}
+
+	return nil
+}
+
 func (e *Evaluator) evalIncludeStatement(node *ast.IncludeStatement, env *Environment, this Object) (Object, *Error) {
 	path := node.Path
+
+	var permErr error
+	if _, ok := GetStdlib(path); ok {
+		permErr = e.permissions.CheckModule(path)
+	} else {
+		var resolveErr error
+		path, resolveErr = e.envManager.Resolve(e.filePath, path)
+		if resolveErr != nil {
+			return nil, e.newError(node, "cannot read file: %s", node.Path)
+		}
+
+		permErr = e.permissions.CheckInclude(path)
+	}
+
+	if permErr != nil {
+		return nil, e.newError(node, "%s", permErr.Error())
+	}
+
+	include := loader.Include{File: e.filePath, Line: node.Span().Start.Line}
+	if chain, ok := loader.Cycle(append(e.including, include), path); ok {
+		return nil, e.newError(node, "circular include: %s", chain)
+	}
+
 	fileEnv, evaluated := e.envManager.Get(path)
 
 	if !evaluated {
-		file, ioErr := ioutil.ReadFile(path)
-		if ioErr != nil {
-			return nil, e.newError(node.Token, "cannot read file: %s", path)
+		input, loadErr := e.envManager.Load(path)
+		if loadErr != nil {
+			return nil, e.newError(node, "cannot read file: %s", path)
 		}
 
-		input := string(file)
 		lexer := lexer.New(input)
 		parser := parser.New(lexer, path)
 		program := parser.ParseProgram()
-		parser.ReportErrors()
+		if errors := parser.ReportErrors(); len(errors) > 0 {
+			return nil, e.newError(node, "syntax errors in %s:\n%s", path, strings.Join(errors, "\n"))
+		}
 
+		e.including = append(e.including, include)
+		filePath := e.enterCall("<include "+path+">", node, path)
 		_, err := e.Eval(program, fileEnv, this)
+		e.exitCall(filePath)
+		e.including = e.including[:len(e.including)-1]
 		if err != nil {
 			return nil, err
 		}
@@ -382,9 +684,19 @@ func (e *Evaluator) evalIncludeStatement(node *ast.IncludeStatement, env *Enviro
 	if node.Alias != nil {
 		includeObject := &IncludeObject{
 			Value: fileEnv,
+			Path:  path,
 		}
 
 		env.AddAlias(node.Alias.Value, includeObject)
+	} else if node.Imports != nil {
+		for _, imp := range node.Imports {
+			obj, err := e.getExport(imp.Name, fileEnv, path, imp.Name.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			env.Let(imp.Alias.Value, obj)
+		}
 	} else {
 		env.Includes = append(env.Includes, fileEnv)
 	}
@@ -392,7 +704,81 @@ func (e *Evaluator) evalIncludeStatement(node *ast.IncludeStatement, env *Enviro
 	return NIL, nil
 }
 
+func (e *Evaluator) evalTryStatement(node *ast.TryStatement, env *Environment, this Object) (Object, *Error) {
+	result, err := e.Eval(node.Body, env, this)
+
+	if err != nil && err.Fatal() {
+		return nil, err
+	}
+
+	if err != nil && node.Catch != nil {
+		catchEnv := NewEnclosedEnvironment(env)
+		if node.CatchParam != nil {
+			catchEnv.Let(node.CatchParam.Value, errorToObject(err))
+		}
+
+		result, err = e.Eval(node.Catch, catchEnv, this)
+	}
+
+	if node.Finally != nil {
+		finallyResult, finallyErr := e.Eval(node.Finally, env, this)
+		if finallyErr != nil {
+			return nil, finallyErr
+		}
+
+		// leaving the finally block overrides the outcome of the try and catch blocks
+		if isReturn(finallyResult) || isLoopControl(finallyResult) {
+			return finallyResult, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (e *Evaluator) evalThrowStatement(node *ast.ThrowStatement, env *Environment, this Object) (Object, *Error) {
+	val, err := e.Eval(node.Value, env, this)
+	if err != nil {
+		return nil, err
+	}
+
+	thrown := e.newError(node, "uncaught exception: %s", val.Inspect())
+	thrown.Value = val
+
+	return nil, thrown
+}
+
+// errorToObject returns the value a catch block receives for the error,
+// thrown values are passed as is and runtime errors are described by a hash
+func errorToObject(err *Error) Object {
+	if err.Value != nil {
+		return err.Value
+	}
+
+	message := &String{Value: "message"}
+	file := &String{Value: "file"}
+	line := &String{Value: "line"}
+	column := &String{Value: "column"}
+
+	return &Hash{
+		Pairs: map[HashKey]Object{
+			message.HashKey(): &String{Value: err.Message},
+			file.HashKey():    &String{Value: err.File},
+			line.HashKey():    Integer{Value: err.Span.Start.Line},
+			column.HashKey():  Integer{Value: err.Span.Start.Column},
+		},
+	}
+}
+
 func (e *Evaluator) evalPrefixExpression(node *ast.PrefixExpression, env *Environment, this Object) (Object, *Error) {
+	if node.Operator == "++" || node.Operator == "--" {
+		_, result, err := e.evalIncrement(node, "prefix", node.Operator, node.Right, env, this)
+		return result, err
+	}
+
 	right, err := e.Eval(node.Right, env, this)
 	if err != nil {
 		return nil, err
@@ -405,7 +791,7 @@ func (e *Evaluator) evalPrefixExpression(node *ast.PrefixExpression, env *Enviro
 		return e.evalMinusPrefixOperatorExpression(node, right)
 
 	default:
-		return nil, e.newError(node.Token, "unknown operator: %s%s", node.Operator, right.Inspect())
+		return nil, e.newError(node, "unknown operator: %s%s", node.Operator, right.Inspect())
 	}
 }
 
@@ -420,7 +806,7 @@ func (e *Evaluator) evalMinusPrefixOperatorExpression(node *ast.PrefixExpression
 	case *Float:
 		return &Float{Value: -right.Value}, nil
 	default:
-		return nil, e.newError(node.Token, "unknown operator: -%s", right.Inspect())
+		return nil, e.newError(node, "unknown operator: -%s", right.Inspect())
 	}
 }
 
@@ -435,53 +821,58 @@ func (e *Evaluator) evalInfixExpression(node *ast.InfixExpression, env *Environm
 		return nil, err
 	}
 
+	return e.evalInfix(node, node.Operator, left, right)
+}
+
+// evalInfix applies the operator of an infix expression or of a compound assignment to its operands
+func (e *Evaluator) evalInfix(node ast.Node, operator string, left, right Object) (Object, *Error) {
 	switch {
 	case left.Type() == IntegerObj && right.Type() == IntegerObj:
 		leftVal := left.(Integer).Value
 		rightVal := right.(Integer).Value
 
-		return e.evalIntegerInfixExpression(node, node.Operator, leftVal, rightVal)
+		return e.evalIntegerInfixExpression(node, operator, leftVal, rightVal)
 
 	case left.Type() == FloatObj && right.Type() == FloatObj:
 		leftVal := left.(*Float).Value
 		rightVal := right.(*Float).Value
 
-		return e.evalFloatInfixExpression(node, node.Operator, leftVal, rightVal)
+		return e.evalFloatInfixExpression(node, operator, leftVal, rightVal)
 
 	case left.Type() == FloatObj && right.Type() == IntegerObj:
 		leftVal := left.(*Float).Value
 		rightVal := right.(Integer).Value
 
-		return e.evalFloatInfixExpression(node, node.Operator, leftVal, float64(rightVal))
+		return e.evalFloatInfixExpression(node, operator, leftVal, float64(rightVal))
 
 	case left.Type() == IntegerObj && right.Type() == FloatObj:
 		leftVal := left.(Integer).Value
 		rightVal := right.(*Float).Value
 
-		return e.evalFloatInfixExpression(node, node.Operator, float64(leftVal), rightVal)
+		return e.evalFloatInfixExpression(node, operator, float64(leftVal), rightVal)
 
 	case left.Type() == StringObj && right.Type() == StringObj:
-		return e.evalStringInfixExpression(node, node.Operator, left, right)
+		return e.evalStringInfixExpression(node, operator, left, right)
 
-	case node.Operator == "==":
+	case operator == "==":
 		return boolToBoolObject(left == right), nil
 
-	case node.Operator == "!=":
+	case operator == "!=":
 		return boolToBoolObject(left != right), nil
 
-	case node.Operator == "&&":
+	case operator == "&&":
 		return boolToBoolObject(isTruthy(left) && isTruthy(right)), nil
 
-	case node.Operator == "||":
+	case operator == "||":
 		return boolToBoolObject(isTruthy(left) || isTruthy(right)), nil
 
 	default:
-		return nil, e.newError(node.Token, "unknown operator: %s %s %s",
-			left.Inspect(), node.Operator, right.Inspect())
+		return nil, e.newError(node, "unknown operator: %s %s %s",
+			left.Inspect(), operator, right.Inspect())
 	}
 }
 
-func (e *Evaluator) evalIntegerInfixExpression(node *ast.InfixExpression, operator string, left, right int) (Object, *Error) {
+func (e *Evaluator) evalIntegerInfixExpression(node ast.Node, operator string, left, right int) (Object, *Error) {
 	switch operator {
 	case "<":
 		return boolToBoolObject(left < right), nil
@@ -501,17 +892,24 @@ func (e *Evaluator) evalIntegerInfixExpression(node *ast.InfixExpression, operat
 		return Integer{Value: left - right}, nil
 	case "*":
 		return Integer{Value: left * right}, nil
-	case "/":
-		return Integer{Value: left / right}, nil
-	case "%":
+	case "/", "%":
+		// compound assignments like x /= 0 get here too, so both are caught as errors
+		if right == 0 {
+			return nil, e.newError(node, "division by zero")
+		}
+
+		if operator == "/" {
+			return Integer{Value: left / right}, nil
+		}
+
 		return Integer{Value: left % right}, nil
 	default:
-		return nil, e.newError(node.Token, "unknown operator: %d %s %d",
+		return nil, e.newError(node, "unknown operator: %d %s %d",
 			left, operator, right)
 	}
 }
 
-func (e *Evaluator) evalFloatInfixExpression(node *ast.InfixExpression, operator string, left, right float64) (Object, *Error) {
+func (e *Evaluator) evalFloatInfixExpression(node ast.Node, operator string, left, right float64) (Object, *Error) {
 	switch operator {
 	case "<":
 		return boolToBoolObject(left < right), nil
@@ -536,19 +934,23 @@ func (e *Evaluator) evalFloatInfixExpression(node *ast.InfixExpression, operator
 	case "%":
 		return &Float{Value: math.Mod(left, right)}, nil
 	default:
-		return nil, e.newError(node.Token, "unknown operator: %f %s %f",
+		return nil, e.newError(node, "unknown operator: %f %s %f",
 			left, operator, right)
 	}
 }
 
-func (e *Evaluator) evalStringInfixExpression(node *ast.InfixExpression, operator string, left, right Object) (Object, *Error) {
+func (e *Evaluator) evalStringInfixExpression(node ast.Node, operator string, left, right Object) (Object, *Error) {
 	if operator != "+" {
-		return nil, e.newError(node.Token, "unknown operator: %s %s %s",
+		return nil, e.newError(node, "unknown operator: %s %s %s",
 			left.Inspect(), operator, right.Inspect())
 	}
 
 	leftVal := left.(*String).Value
 	rightVal := right.(*String).Value
+	if err := e.budget.StringSize(len(leftVal) + len(rightVal)); err != nil {
+		return nil, e.newFatalError(node, err)
+	}
+
 	return &String{Value: leftVal + rightVal}, nil
 }
 
@@ -580,7 +982,7 @@ func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *Environment, this
 		return builtin, nil
 	}
 
-	return nil, e.newError(node.Token, "identifier not found: "+node.Value)
+	return nil, e.newError(node, "identifier not found: "+node.Value)
 }
 
 func (e *Evaluator) evalArrayLiteral(node *ast.ArrayLiteral, env *Environment, this Object) (Object, *Error) {
@@ -598,6 +1000,25 @@ func (e *Evaluator) evalArrayLiteral(node *ast.ArrayLiteral, env *Environment, t
 	return &Array{Value: objects}, nil
 }
 
+// evalTemplateLiteral joins the strings and the values of the interpolations of a template as Inspect shows them
+func (e *Evaluator) evalTemplateLiteral(node *ast.TemplateLiteral, env *Environment, this Object) (Object, *Error) {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		obj, err := e.Eval(part, env, this)
+		if err != nil {
+			return nil, err
+		}
+
+		out.WriteString(obj.Inspect())
+		if err := e.budget.StringSize(out.Len()); err != nil {
+			return nil, e.newFatalError(node, err)
+		}
+	}
+
+	return &String{Value: out.String()}, nil
+}
+
 func (e *Evaluator) evalIndexExpression(node *ast.IndexExpression, env *Environment, this Object) (Object, *Error) {
 	left, err := e.Eval(node.Left, env, this)
 	if err != nil {
@@ -609,11 +1030,33 @@ func (e *Evaluator) evalIndexExpression(node *ast.IndexExpression, env *Environm
 		return nil, err
 	}
 
+	return e.evalIndex(node, left, index)
+}
+
+func (e *Evaluator) evalIndex(node *ast.IndexExpression, left, index Object) (Object, *Error) {
 	switch left := left.(type) {
 	case *Array:
 		switch index.Type() {
 		case IntegerObj:
 			return e.evalArrayIndexExpression(node, left, index)
+		case RangeObj:
+			return e.evalArraySliceExpression(node, left, index.(*Range))
+		default:
+			return e.evalWithFunctionsIndexExpression(node, left, index)
+		}
+
+	case *String:
+		switch index.Type() {
+		case RangeObj:
+			return e.evalStringSliceExpression(node, left, index.(*Range))
+		default:
+			return e.evalWithFunctionsIndexExpression(node, left, index)
+		}
+
+	case *Range:
+		switch index.Type() {
+		case IntegerObj:
+			return e.evalRangeIndexExpression(node, left, index)
 		default:
 			return e.evalWithFunctionsIndexExpression(node, left, index)
 		}
@@ -628,7 +1071,7 @@ func (e *Evaluator) evalIndexExpression(node *ast.IndexExpression, env *Environm
 		return e.evalWithFunctionsIndexExpression(node, left, index)
 
 	default:
-		return nil, e.newError(node.Token, "index operator not supported: %s", left.Inspect())
+		return nil, e.newError(node, "index operator not supported: %s", left.Inspect())
 	}
 }
 
@@ -643,7 +1086,7 @@ func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *Environment) (Ob
 
 		key, ok := hashKey.(Hashable)
 		if !ok {
-			return nil, e.newError(node.Token, "unusable as hash key: %s", hashKey.Inspect())
+			return nil, e.newError(node, "unusable as hash key: %s", hashKey.Inspect())
 		}
 
 		hashValue, err := e.Eval(value, env, hash)
@@ -651,6 +1094,12 @@ func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *Environment) (Ob
 			return nil, err
 		}
 
+		if fn, ok := hashValue.(*Function); ok && fn.Name == "" {
+			if name, ok := hashKey.(*String); ok {
+				fn.Name = name.Value
+			}
+		}
+
 		hash.Pairs[key.HashKey()] = hashValue
 	}
 
@@ -668,10 +1117,43 @@ func (e *Evaluator) evalArrayIndexExpression(node *ast.IndexExpression, array *A
 	return array.Value[idx], nil
 }
 
+// evalArraySliceExpression returns a new array with the elements at the indexes of the range
+func (e *Evaluator) evalArraySliceExpression(node *ast.IndexExpression, array *Array, index *Range) (Object, *Error) {
+	indexes := index.Indexes(len(array.Value))
+	elements := make([]Object, len(indexes))
+	for i, idx := range indexes {
+		elements[i] = array.Value[idx]
+	}
+
+	return &Array{Value: elements}, nil
+}
+
+// evalStringSliceExpression returns the characters of the string at the indexes of the range
+func (e *Evaluator) evalStringSliceExpression(node *ast.IndexExpression, str *String, index *Range) (Object, *Error) {
+	runes := []rune(str.Value)
+	indexes := index.Indexes(len(runes))
+	slice := make([]rune, len(indexes))
+	for i, idx := range indexes {
+		slice[i] = runes[idx]
+	}
+
+	return &String{Value: string(slice)}, nil
+}
+
+func (e *Evaluator) evalRangeIndexExpression(node *ast.IndexExpression, r *Range, index Object) (Object, *Error) {
+	idx, _ := integerValue(index)
+	n, ok := r.At(idx)
+	if !ok {
+		return NIL, nil
+	}
+
+	return Integer{Value: n}, nil
+}
+
 func (e *Evaluator) evalHashIndexExpression(node *ast.IndexExpression, hash *Hash, index Object) (Object, *Error) {
 	key, ok := index.(Hashable)
 	if !ok {
-		return nil, e.newError(node.Token, "unusable as hash key: %s", index.Inspect())
+		return nil, e.newError(node, "unusable as hash key: %s", index.Inspect())
 	}
 
 	if val, ok := hash.Pairs[key.HashKey()]; ok {
@@ -685,27 +1167,35 @@ func (e *Evaluator) evalIncludeIndexExpression(node *ast.IndexExpression, includ
 	includeObj := include.(*IncludeObject)
 	key, ok := index.(*String)
 	if !ok {
-		return nil, e.newError(node.Token, "unusable as include key: %s", key.Inspect())
+		return nil, e.newError(node, "unusable as include key: %s", index.Inspect())
 	}
 
-	obj, ok := includeObj.Value.Store[key.Value]
-	if !ok {
-		return nil, e.newError(node.Token, "include key not found: %s", key.Inspect())
+	return e.getExport(node, includeObj.Value, includeObj.Path, key.Value)
+}
+
+// getExport returns a variable of an included file, it fails if the file doesn't declare it or doesn't export it
+func (e *Evaluator) getExport(node ast.Node, fileEnv *Environment, path string, name string) (Object, *Error) {
+	if obj, ok := fileEnv.GetExported(name); ok {
+		return obj, nil
 	}
 
-	return obj, nil
+	if fileEnv.Declares(name) && !fileEnv.IsExported(name) {
+		return nil, e.newError(node, "%s is not exported by %s", name, path)
+	}
+
+	return nil, e.newError(node, "include key not found: %s", name)
 }
 
 func (e *Evaluator) evalWithFunctionsIndexExpression(node *ast.IndexExpression, obj ObjectWithFunctions, index Object) (Object, *Error) {
 	name, ok := index.(*String)
 	if !ok {
-		return nil, e.newError(node.Token, "cannot use %s as an index", index.Type().String())
+		return nil, e.newError(node, "cannot use %s as an index", index.Type().String())
 	}
 
 	fn, ok := obj.GetFunction(name.Value)
 	if !ok {
 		return nil, e.newError(
-			node.Token, "cannot find '%s' function on type %s",
+			node, "cannot find '%s' function on type %s",
 			name.Value,
 			obj.(Object).Type().String(),
 		)
@@ -715,6 +1205,19 @@ func (e *Evaluator) evalWithFunctionsIndexExpression(node *ast.IndexExpression,
 }
 
 func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *Environment, this Object) (Object, *Error) {
+	if node.Operator != "" {
+		_, result, err := e.evalUpdate(node, node.Name, env, this, func(old Object) (Object, *Error) {
+			val, err := e.Eval(node.Value, env, this)
+			if err != nil {
+				return nil, err
+			}
+
+			return e.evalInfix(node, node.Operator, old, val)
+		})
+
+		return result, err
+	}
+
 	val, err := e.Eval(node.Value, env, this)
 	if err != nil {
 		return nil, err
@@ -728,17 +1231,17 @@ func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *Enviro
 		return e.evalAssingIndexExpression(node, left, val, env, this)
 	}
 
-	return nil, e.newError(node.Token, "cannot assign to %s", node.Name.String())
+	return nil, e.newError(node, "cannot assign to %s", node.Name.String())
 }
 
-func (e *Evaluator) evalAssingIdentifierExpression(node *ast.AssignExpression, left *ast.Identifier, val Object, env *Environment) (Object, *Error) {
+func (e *Evaluator) evalAssingIdentifierExpression(node ast.Node, left *ast.Identifier, val Object, env *Environment) (Object, *Error) {
 	if env.IsConstant(left.Value) {
-		return nil, e.newError(node.Token, "cannot assign to a constant variable %s", left.Value)
+		return nil, e.newError(node, "cannot assign to a constant variable %s", left.Value)
 	}
 
 	_, ok := env.Set(left.Value, val)
 	if !ok {
-		return nil, e.newError(node.Token, "identifier not found: "+left.Value)
+		return nil, e.newError(node, "identifier not found: "+left.Value)
 	}
 
 	return val, nil
@@ -755,22 +1258,32 @@ func (e *Evaluator) evalAssingIndexExpression(node *ast.AssignExpression, left *
 		return nil, err
 	}
 
+	return e.evalSetIndex(node, leftObj, index, val)
+}
+
+// evalSetIndex stores the value at the index of an array or at the key of a hash
+func (e *Evaluator) evalSetIndex(node ast.Node, leftObj, index, val Object) (Object, *Error) {
 	switch leftObj := leftObj.(type) {
 	case *Array:
 		return e.evalAssingArrayIndexExpression(node, leftObj, index, val)
 	case *Hash:
 		return e.evalAssingHashIndexExpression(node, leftObj, index, val)
 	default:
-		return nil, e.newError(node.Token, "index operator not supported: %s", leftObj.Inspect())
+		return nil, e.newError(node, "index operator not supported: %s", leftObj.Inspect())
 	}
 }
 
-func (e *Evaluator) evalAssingArrayIndexExpression(node *ast.AssignExpression, leftObj *Array, index Object, val Object) (Object, *Error) {
-	idx := index.(Integer).Value
+func (e *Evaluator) evalAssingArrayIndexExpression(node ast.Node, leftObj *Array, index Object, val Object) (Object, *Error) {
+	idxObj, ok := index.(Integer)
+	if !ok {
+		return nil, e.newError(node, "cannot use %s as an index", index.Type())
+	}
+
+	idx := idxObj.Value
 	max := len(leftObj.Value) - 1
 
 	if idx < 0 || idx > max {
-		return nil, e.newError(node.Token, "index out of bounds")
+		return nil, e.newError(node, "index out of bounds")
 	}
 
 	leftObj.Value[idx] = val
@@ -778,10 +1291,10 @@ func (e *Evaluator) evalAssingArrayIndexExpression(node *ast.AssignExpression, l
 	return val, nil
 }
 
-func (e *Evaluator) evalAssingHashIndexExpression(node *ast.AssignExpression, leftObj *Hash, index Object, val Object) (Object, *Error) {
+func (e *Evaluator) evalAssingHashIndexExpression(node ast.Node, leftObj *Hash, index Object, val Object) (Object, *Error) {
 	key, ok := index.(Hashable)
 	if !ok {
-		return nil, e.newError(node.Token, "unusable as hash key: %s", index.Inspect())
+		return nil, e.newError(node, "unusable as hash key: %s", index.Inspect())
 	}
 
 	leftObj.Pairs[key.HashKey()] = val
@@ -790,34 +1303,109 @@ func (e *Evaluator) evalAssingHashIndexExpression(node *ast.AssignExpression, le
 }
 
 func (e *Evaluator) evalPostfixExpression(node *ast.PostfixExpression, env *Environment, this Object) (Object, *Error) {
-	left, err := e.Eval(node.Left, env, this)
-	if err != nil {
-		return nil, err
-	}
+	old, _, err := e.evalIncrement(node, "postfix", node.Operator, node.Left, env, this)
 
-	switch left := left.(type) {
-	case Integer:
-		return e.evalPostfixIntegerExpression(node, node.Operator, left)
-	default:
-		return nil, e.newError(node.Token, "postfix operator not supported: %s", left.Inspect())
+	return old, err
+}
+
+// evalIncrement adds one to a number with ++ or subtracts one from it with --,
+// kind is the kind of the operator for the error if the target isn't a number
+func (e *Evaluator) evalIncrement(node ast.Node, kind, operator string, target ast.Expression, env *Environment, this Object) (old, result Object, err *Error) {
+	return e.evalUpdate(node, target, env, this, func(old Object) (Object, *Error) {
+		if old.Type() != IntegerObj && old.Type() != FloatObj {
+			return nil, e.newError(node, "%s operator not supported: %s", kind, old.Inspect())
+		}
+
+		return e.evalInfix(node, operator[:1], old, Integer{Value: 1})
+	})
+}
+
+// evalUpdate stores the result of update in the target, a variable or an element of an array or a hash,
+// update is called with the value the target has and the old value is returned with the result
+func (e *Evaluator) evalUpdate(node ast.Node, target ast.Expression, env *Environment, this Object, update func(old Object) (Object, *Error)) (old, result Object, err *Error) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		old, err = e.Eval(target, env, this)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result, err = update(old)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, err = e.evalAssingIdentifierExpression(node, target, result, env)
+
+		return old, result, err
+
+	case *ast.IndexExpression:
+		left, err := e.Eval(target.Left, env, this)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		index, err := e.Eval(target.Index, env, this)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		old, err = e.evalIndex(target, left, index)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result, err = update(old)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, err = e.evalSetIndex(node, left, index, result)
+
+		return old, result, err
 	}
+
+	return nil, nil, e.newError(node, "cannot assign to %s", target.String())
 }
 
-func (e *Evaluator) evalPostfixIntegerExpression(node *ast.PostfixExpression, operator string, left Integer) (Object, *Error) {
-	switch operator {
-	case "++":
-		left.Value++
-		return left, nil
-	case "--":
-		left.Value--
-		return left, nil
-	default:
-		return nil, e.newError(node.Token, "postfix operator not supported: %s", operator)
+func (e *Evaluator) newError(node ast.Node, format string, a ...interface{}) *Error {
+	err := &Error{
+		Message: fmt.Sprintf(format, a...),
+		File:    e.filePath,
+		Span:    node.Span(),
 	}
+	err.Stack = e.stackTrace(err.File, err.Span)
+
+	if source, ok := e.envManager.Source(e.filePath); ok {
+		err.Snippet = err.Span.Highlight(source)
+	}
+
+	return err
+}
+
+// newFatalError returns an error the script can't catch, like going over its limits
+func (e *Evaluator) newFatalError(node ast.Node, cause error) *Error {
+	err := e.newError(node, "%s", cause.Error())
+	err.Err = cause
+
+	return err
 }
 
-func (e *Evaluator) newError(token token.Token, format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf("[file %s:%d] %s", e.filePath, token.Line, fmt.Sprintf(format, a...))}
+// checkSize returns an error if the result of a builtin is bigger than the limits allow
+func (e *Evaluator) checkSize(node ast.Node, obj Object) *Error {
+	var err error
+	switch obj := obj.(type) {
+	case *Array:
+		err = e.budget.ArraySize(len(obj.Value))
+	case *String:
+		err = e.budget.StringSize(len(obj.Value))
+	}
+
+	if err != nil {
+		return e.newFatalError(node, err)
+	}
+
+	return nil
 }
 
 func isTruthy(obj Object) bool {
@@ -849,3 +1437,7 @@ func isReturn(obj Object) bool {
 
 	return rt == ReturnValueObj
 }
+
+func isLoopControl(obj Object) bool {
+	return obj == BREAK || obj == CONTINUE
+}
@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"path"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/loader"
 	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -446,6 +453,80 @@ func TestWhileLoop(t *testing.T) {
 	}
 }
 
+func TestBreakContinue(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected Object
+	}{
+		{
+			`
+			let x = 0;
+
+			while (true) {
+				x = x + 1;
+				if (x == 5) { break; }
+			}
+
+			x
+			`,
+			Integer{Value: 5},
+		},
+		{
+			`
+			let x = 0;
+
+			for (let i = 0; i < 10; i = i + 1) {
+				if (i % 2 == 0) { continue; }
+				x = x + i;
+			}
+
+			x
+			`,
+			Integer{Value: 25},
+		},
+		{
+			`
+			let x = 0;
+
+			for (let i = 0; i < 3; i = i + 1) {
+				let j = 0;
+				while (true) {
+					j = j + 1;
+					if (j > i) { break; }
+					x = x + 1;
+				}
+			}
+
+			x
+			`,
+			Integer{Value: 3},
+		},
+		{
+			`
+			let f = fn() {
+				let i = 0;
+				while (true) {
+					i = i + 1;
+					if (i == 3) { return i; }
+				}
+			};
+
+			f()
+			`,
+			Integer{Value: 3},
+		},
+	}
+
+	for _, tc := range tests {
+		evaluated, err := testEval(tc.input)
+		assert.Nil(err)
+		assert.IsType(tc.expected, evaluated)
+		assert.Equal(tc.expected, evaluated)
+	}
+}
+
 func TestRecursion(t *testing.T) {
 	assert := assert.New(t)
 
@@ -478,6 +559,78 @@ func TestRecursion(t *testing.T) {
 	}
 }
 
+func TestTryCatch(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected Object
+	}{
+		{`let x = 0; try { throw 5; x = 1; } catch (e) { x = e; } x`, Integer{Value: 5}},
+		{`let x = 0; try { x = 1; } catch (e) { x = 2; } x`, Integer{Value: 1}},
+		{`let x = 0; try { x = 1; } finally { x = x + 1; } x`, Integer{Value: 2}},
+		{`let x = 0; try { throw 1; } catch { x = 1; } finally { x = x + 1; } x`, Integer{Value: 2}},
+		{`let x = ""; try { let y = 1 + true; } catch (e) { x = e.message; } x`, &String{Value: "unknown operator: 1 + true"}},
+		{`let x = 0; try { [1].map(fn(v) { throw v + 1; }); } catch (e) { x = e; } x`, Integer{Value: 2}},
+		{`let f = fn() { try { return 1; } finally { throw 2; } }; let x = 0; try { f(); } catch (e) { x = e; } x`, Integer{Value: 2}},
+		{`let x = 0; try { try { throw 1; } finally { x = 10; } } catch (e) { x = x + e; } x`, Integer{Value: 11}},
+		{
+			`
+			let i = 0;
+			let x = 0;
+			while (true) {
+				try { i = i + 1; if (i == 3) { break; } } finally { x = x + 10; }
+			}
+			x
+			`,
+			Integer{Value: 30},
+		},
+	}
+
+	for _, tc := range tests {
+		evaluated, err := testEval(tc.input)
+		assert.Nil(err)
+		assert.IsType(tc.expected, evaluated)
+		assert.Equal(tc.expected, evaluated)
+	}
+
+	_, err := testEval(`throw "boom";`)
+	assert.NotNil(err)
+	assert.Equal("uncaught exception: boom", err.Message)
+	assert.Equal(&String{Value: "boom"}, err.Value)
+}
+
+func TestErrorPosition(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := testEval("let x = 1;\nlet y = x + true;")
+	assert.NotNil(err)
+	assert.Equal("unknown operator: 1 + true", err.Message)
+	assert.Equal(fileName, err.File)
+	assert.Equal(2, err.Span.Start.Line)
+	assert.Equal(9, err.Span.Start.Column)
+	assert.Equal(17, err.Span.End.Column)
+}
+
+func TestStackTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `
+	let inner = fn() { missing };
+	let outer = fn() { inner() };
+	fn() { outer() }();
+	`
+
+	_, err := testEval(input)
+	assert.NotNil(err)
+	assert.Equal([]StackFrame{
+		{Function: "inner", File: fileName, Line: 2, Column: 21},
+		{Function: "outer", File: fileName, Line: 3, Column: 21},
+		{Function: "<anonymous>", File: fileName, Line: 4, Column: 9},
+		{Function: "<main>", File: fileName, Line: 4, Column: 2},
+	}, err.Stack)
+}
+
 func testEval(input string) (Object, *Error) {
 	l := lexer.New(input)
 	p := parser.New(l, fileName)
@@ -489,3 +642,325 @@ func testEval(input string) (Object, *Error) {
 
 	return evaluator.Eval(program, env, nil)
 }
+
+func TestStdio(t *testing.T) {
+	input := `
+		echo "hello";
+		print("a", 1);
+		println();
+		let name = input("name: ");
+		println(name, input());
+	`
+
+	l := lexer.New(input)
+	p := parser.New(l, fileName)
+	program := p.ParseProgram()
+
+	envManager := NewEnvironmentManager()
+	env, _ := envManager.Get(fileName)
+	evaluator := New(envManager, fileName)
+
+	var out bytes.Buffer
+	evaluator.SetStdout(&out)
+	evaluator.SetStdin(strings.NewReader("wind\nlang"))
+
+	_, err := evaluator.Eval(program, env, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\na1\nname: wind lang\n", out.String())
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		limits  sandbox.Limits
+		message string
+	}{
+		{`while (true) {}`, sandbox.Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+		{`let f = fn(n) { f(n + 1) }; f(0);`, sandbox.Limits{MaxCallDepth: 50}, "limit exceeded: more than 50 nested calls"},
+		{`let s = "ab"; while (true) { s = s + s; }`, sandbox.Limits{MaxStringSize: 1000}, "limit exceeded: string of more than 1000 bytes"},
+		{`while (true) { try { while (true) {} } catch (e) {} }`, sandbox.Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l, fileName)
+		program := p.ParseProgram()
+
+		envManager := NewEnvironmentManager()
+		env, _ := envManager.Get(fileName)
+		evaluator := New(envManager, fileName)
+		evaluator.SetLimits(context.Background(), tt.limits)
+
+		_, err := evaluator.Eval(program, env, nil)
+		if assert.NotNil(t, err, tt.input) {
+			assert.ErrorIs(t, err, sandbox.ErrLimitExceeded, tt.input)
+			assert.Equal(t, tt.message, err.Message, tt.input)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		input   string
+		modules []string
+		message string
+	}{
+		{`include "fs" as fs; fs.readFile("/etc/hostname");`, nil, "permission denied: cannot access /etc/hostname, it's outside the allowed directories"},
+		{`include "fs" as fs; fs.writeFile("/tmp/x.txt", "x");`, nil, "permission denied: cannot access /tmp/x.txt, it's outside the allowed directories"},
+		{`include "request" as request; request.get("http://localhost");`, nil, "permission denied: network access to http://localhost is not allowed"},
+		{`include "math" as math; include "request" as request;`, []string{"math"}, "permission denied: module request is not allowed"},
+		{`include "/etc/other.wind";`, nil, "permission denied: cannot include /etc/other.wind, it's outside the allowed directories"},
+		{`try { include "request" as request; } catch (e) { throw e.message + "!"; }`, []string{}, "uncaught exception: permission denied: module request is not allowed!"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l, fileName)
+		program := p.ParseProgram()
+
+		envManager := NewEnvironmentManager()
+		env, _ := envManager.Get(fileName)
+		evaluator := New(envManager, fileName)
+		evaluator.SetPermissions(&sandbox.Permissions{Modules: tt.modules})
+
+		_, err := evaluator.Eval(program, env, nil)
+		if assert.NotNil(t, err, tt.input) {
+			assert.Equal(t, tt.message, err.Message, tt.input)
+		}
+	}
+}
+
+func TestCircularInclude(t *testing.T) {
+	files := fstest.MapFS{
+		"a.wind":    {Data: []byte("let x = 1;\ninclude \"./b.wind\";")},
+		"b.wind":    {Data: []byte("include \"./c.wind\";")},
+		"c.wind":    {Data: []byte("\n\ninclude \"./a.wind\";")},
+		"self.wind": {Data: []byte("include \"./self\";")},
+		"ok.wind":   {Data: []byte("include \"./c2.wind\";\ninclude \"./c2.wind\";")},
+		"c2.wind":   {Data: []byte("let y = 2;")},
+	}
+
+	tests := []struct {
+		file    string
+		message string
+	}{
+		{"a.wind", "circular include: a.wind:2 -> b.wind:1 -> c.wind:3 -> a.wind"},
+		{"./a.wind", "circular include: a.wind:2 -> b.wind:1 -> c.wind:3 -> a.wind"},
+		{"self.wind", "circular include: self.wind:1 -> self.wind"},
+		{"ok.wind", ""},
+	}
+
+	for _, tt := range tests {
+		envManager := NewEnvironmentManager()
+		envManager.SetLoader(loader.NewFS(files))
+
+		source, loadErr := envManager.Load(path.Clean(tt.file))
+		assert.Nil(t, loadErr)
+
+		program := parser.New(lexer.New(source), tt.file).ParseProgram()
+		env, _ := envManager.Get(tt.file)
+
+		_, err := New(envManager, tt.file).Eval(program, env, nil)
+		if tt.message == "" {
+			assert.Nil(t, err, tt.file)
+		} else if assert.NotNil(t, err, tt.file) {
+			assert.Equal(t, tt.message, err.Message, tt.file)
+		}
+	}
+}
+
+func TestExports(t *testing.T) {
+	files := fstest.MapFS{
+		"lib.wind": {Data: []byte(`
+			let helper = fn(x) { x * 2 };
+			export let double = fn(x) { helper(x) };
+			export const name = "lib";
+		`)},
+		"all.wind": {Data: []byte(`let everything = 1;`)},
+		"wrap.wind": {Data: []byte(`
+			include "./all.wind";
+			export let wrapped = everything;
+		`)},
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`include "./lib.wind" import { double, name as libName }; [double(2), libName]`, []interface{}{4, "lib"}},
+		{`include "./lib.wind" as lib; [lib.double(3), lib.name]`, []interface{}{6, "lib"}},
+		{`include "./lib.wind"; double(5)`, 10},
+		{`include "./all.wind" import { everything }; everything`, 1},
+		{`include "math" import { abs as absolute }; absolute(-1.5)`, 1.5},
+		{`include "./lib.wind" import { helper };`, "helper is not exported by lib.wind"},
+		{`include "./lib.wind" import { missing };`, "include key not found: missing"},
+		{`include "./lib.wind" as lib; lib.helper(1)`, "helper is not exported by lib.wind"},
+		{`include "./lib.wind" as lib; lib.println(1)`, "include key not found: println"},
+		{`include "./wrap.wind" as wrap; wrap.everything`, "include key not found: everything"},
+		{`include "./lib.wind" as lib; try { lib.helper(1) } catch (e) { [e["message"]] }`, []interface{}{"helper is not exported by lib.wind"}},
+		{`include "./lib.wind"; helper(1)`, "identifier not found: helper"},
+	}
+
+	for _, tt := range tests {
+		envManager := NewEnvironmentManager()
+		envManager.SetLoader(loader.NewFS(files))
+		env, _ := envManager.Get(fileName)
+
+		program := parser.New(lexer.New(tt.input), fileName).ParseProgram()
+		result, err := New(envManager, fileName).Eval(program, env, nil)
+
+		if message, ok := tt.expected.(string); ok {
+			if assert.NotNil(t, err, tt.input) {
+				assert.Equal(t, message, err.Message, tt.input)
+			}
+
+			continue
+		}
+
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+}
+
+func TestForIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let out = []; for (x in [1, 2, 3]) { out.push(x * 2); } out`, []interface{}{2, 4, 6}},
+		{`let out = []; for (i, x in ["a", "b"]) { out.push([i, x]); } out`, []interface{}{[]interface{}{0, "a"}, []interface{}{1, "b"}}},
+		{`let out = []; for (k in {"b": 2, "a": 1}) { out.push(k); } out`, []interface{}{"a", "b"}},
+		{`let out = []; for (k, v in {"b": 2, "a": 1}) { out.push([k, v]); } out`, []interface{}{[]interface{}{"a", 1}, []interface{}{"b", 2}}},
+		{`let out = []; for (i, ch in "hé") { out.push([i, ch]); } out`, []interface{}{[]interface{}{0, "h"}, []interface{}{1, "é"}}},
+		{`let out = []; for (i in 2..5) { out.push(i); } out`, []interface{}{2, 3, 4}},
+		{`let out = []; for (n, i in 5..3) { out.push(i); } out`, []interface{}{}},
+		{`let out = []; for (i in 0..10) { if (i % 2 == 0) { continue; } if (i > 5) { break; } out.push(i); } out`, []interface{}{1, 3, 5}},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } }; f()`, 20},
+		{`let fns = []; for (x in [1, 2]) { fns.push(fn() { x }); } [fns[0](), fns[1]()]`, []interface{}{1, 2}},
+		{`for (x in 1) {}`, "cannot iterate over INTEGER"},
+		{`for (i in 0..1.5) {}`, "range bounds must be integers, got INTEGER..FLOAT"},
+	}
+
+	for _, tt := range tests {
+		result, err := testEval(tt.input)
+
+		if message, ok := tt.expected.(string); ok {
+			if assert.NotNil(t, err, tt.input) {
+				assert.Equal(t, message, err.Message, tt.input)
+			}
+
+			continue
+		}
+
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`(0..5).toArray()`, []interface{}{0, 1, 2, 3, 4}},
+		{`(0..=5).toArray()`, []interface{}{0, 1, 2, 3, 4, 5}},
+		{`(0..10).step(3).toArray()`, []interface{}{0, 3, 6, 9}},
+		{`(5..=0).step(-2).toArray()`, []interface{}{5, 3, 1}},
+		{`[(0..10).len(), (0..=10).len(), (5..0).len(), (0..10).step(4).len()]`, []interface{}{10, 11, 0, 3}},
+		{`[(0..10)[3], (0..10)[10], (0..10).step(2)[4], (1..)[100]]`, []interface{}{3, nil, 8, 101}},
+		{`[(0..10).contains(9), (0..10).contains(10), (0..=10).contains(10), (0..10).step(3).contains(4), (0..).contains(1000)]`, []interface{}{true, false, true, false, true}},
+		{`let a = [1, 2, 3, 4, 5]; [a[1..3], a[3..], a[-2..2], a[1..=10]]`, []interface{}{[]interface{}{2, 3}, []interface{}{4, 5}, []interface{}{1, 2}, []interface{}{2, 3, 4, 5}}},
+		{`let a = [1, 2, 3, 4, 5]; [a[(4..).step(-2)], a[(0..).step(2)]]`, []interface{}{[]interface{}{5, 3, 1}, []interface{}{1, 3, 5}}},
+		{`let s = "héllo"; [s[1..3], s[2..], s[0..=1], s[10..]]`, []interface{}{"él", "llo", "hé", ""}},
+		{`let out = []; for (i in (0..10).step(4)) { out.push(i); } out`, []interface{}{0, 4, 8}},
+		{`let out = []; for (i in 3..) { if (i > 5) { break; } out.push(i); } out`, []interface{}{3, 4, 5}},
+		{`let r = 0..3; let a = []; for (i in r) { a.push(i); } for (i in r) { a.push(i); } a`, []interface{}{0, 1, 2, 0, 1, 2}},
+		{`"a"..3`, "range bounds must be integers, got STRING..INTEGER"},
+		{`(0..).len()`, "an open range has no length"},
+		{`(0..).toArray()`, "an open range cannot be converted to an array"},
+		{`(0..3).step(0)`, "the step of a range cannot be 0"},
+	}
+
+	for _, tt := range tests {
+		result, err := testEval(tt.input)
+
+		if message, ok := tt.expected.(string); ok {
+			if assert.NotNil(t, err, tt.input) {
+				assert.Equal(t, message, err.Message, tt.input)
+			}
+
+			continue
+		}
+
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = {"name": "Joe"}; let count = 3; "Hello ${user.name}, you have ${count} items"`, "Hello Joe, you have 3 items"},
+		{`"${1 + 2}${"b"}${nil} ${true} ${0..3}"`, "3bnil true 0..3"},
+		{`let x = 2; "a ${"b ${x * 2} c"} d"`, "a b 4 c d"},
+		{`"${ {"k": "v"}["k"] }"`, "v"},
+		{`let f = fn(n) { "n=${n}" }; f(1) + f(2)`, "n=1n=2"},
+		{"`raw ${x} \\n \"`", `raw ${x} \n "`},
+	}
+
+	for _, tt := range tests {
+		result, err := testEval(tt.input)
+
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+
+	_, err := testEval(`"${missing}"`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "identifier not found: missing", err.Message)
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; x`, 2},
+		{`let x = 1; [x += 2, x]`, []interface{}{3, 3}},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{`let f = 1.5; f += 1; f++; ++f; f`, 4.5},
+		{`let a = [1, 2, 3]; a[0] += 10; a[1]++; --a[2]; a`, []interface{}{11, 3, 2}},
+		{`let h = {"n": 1}; h["n"] += 1; h.n *= 5; h.n++; h.n`, 11},
+		{`let x = 5; [++x, x++, x, --x, x--, x]`, []interface{}{6, 6, 7, 6, 6, 5}},
+		{`let a = [0]; let i = 0; a[i++] += 1; [a, i]`, []interface{}{[]interface{}{1}, 1}},
+		{`let c = {"value": 0, "inc": fn() { this.value++; return ++this.value; }}; [c.inc(), c.value]`, []interface{}{2, 2}},
+		{`const x = 1; x += 1;`, "cannot assign to a constant variable x"},
+		{`let s = "a"; s++;`, "postfix operator not supported: a"},
+		{`let s = "a"; ++s;`, "prefix operator not supported: a"},
+		{`let a = [1]; a["x"] = 1;`, "cannot use STRING as an index"},
+		{`++(1 + 2);`, "cannot assign to (1 + 2)"},
+		{`let d = 10; d /= 0;`, "division by zero"},
+		{`let a = [10]; a[0] %= 0;`, "division by zero"},
+		{`let d = 10; let caught = nil; try { d /= 0; } catch (e) { caught = e["message"]; } [caught, d]`, []interface{}{"division by zero", 10}},
+	}
+
+	for _, tt := range tests {
+		result, err := testEval(tt.input)
+
+		if message, ok := tt.expected.(string); ok && err != nil {
+			assert.Equal(t, message, err.Message, tt.input)
+			continue
+		}
+
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 
 	"github.com/joetifa2003/windlang/ast"
+	"github.com/joetifa2003/windlang/sandbox"
+	"github.com/joetifa2003/windlang/token"
 )
 
 type ObjectType int
@@ -18,6 +21,8 @@ const (
 	BooleanObj
 	NilObj
 	ReturnValueObj
+	BreakObj
+	ContinueObj
 	ErrorObj
 	FunctionObj
 	StringObj
@@ -25,6 +30,7 @@ const (
 	ArrayObj
 	HashObj
 	IncludeObj
+	RangeObj
 )
 
 func (ot ObjectType) String() string {
@@ -39,6 +45,10 @@ func (ot ObjectType) String() string {
 		return "NIL"
 	case ReturnValueObj:
 		return "RETURN_VALUE"
+	case BreakObj:
+		return "BREAK"
+	case ContinueObj:
+		return "CONTINUE"
 	case ErrorObj:
 		return "ERROR"
 	case FunctionObj:
@@ -53,6 +63,8 @@ func (ot ObjectType) String() string {
 		return "HASH"
 	case IncludeObj:
 		return "INCLUDE"
+	case RangeObj:
+		return "RANGE"
 	default:
 		return "UNKNOWN"
 	}
@@ -164,14 +176,90 @@ func (rv ReturnValue) Clone() Object {
 	return &rv
 }
 
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BreakObj }
+func (b *Break) Inspect() string  { return "break" }
+func (b Break) Clone() Object {
+	return &b
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return ContinueObj }
+func (c *Continue) Inspect() string  { return "continue" }
+func (c Continue) Clone() Object {
+	return &c
+}
+
 type Error struct {
 	Message string
+	File    string
+	Span    token.Span
+	Snippet string       // the highlighted source line of the error, empty if the source is unknown
+	Stack   []StackFrame // the calls that led to the error, most recent first
+	Value   Object       // the value passed to throw, nil for runtime errors
+	Err     error        // the error that stopped the script for errors it can't catch, see Fatal
 }
 
 func (e *Error) Type() ObjectType { return ErrorObj }
-func (e *Error) Inspect() string  { return e.Message }
+
+// Unwrap returns the error that stopped the script, nil for errors it can catch
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Fatal reports whether the script can't catch the error because it went over its limits or it was cancelled
+func (e *Error) Fatal() bool {
+	return sandbox.IsFatal(e.Err)
+}
+
+// Error returns the location and the message of the error on one line
+func (e *Error) Error() string {
+	return fmt.Sprintf("[file %s:%d:%d] %s", e.File, e.Span.Start.Line, e.Span.Start.Column, e.Message)
+}
+
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Error())
+
+	if e.Snippet != "" {
+		out.WriteString("\n")
+		out.WriteString(e.Snippet)
+	}
+
+	return out.String()
+}
+
+// StackTrace returns the call stack of the error, one frame per line
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	out.WriteString("stack trace:")
+	for _, frame := range e.Stack {
+		out.WriteString("\n  at ")
+		out.WriteString(frame.String())
+	}
+
+	return out.String()
+}
+
+// StackFrame is the location reached inside a function when an error happened
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+}
+
+func (sf StackFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d:%d)", sf.Function, sf.File, sf.Line, sf.Column)
+}
 
 type Function struct {
+	Name       string // the name the function was first bound to, empty for anonymous functions
+	FilePath   string // the file the function was declared in
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -237,8 +325,31 @@ func (h Hash) Clone() Object {
 	return &h
 }
 
+// SortedKeys returns the keys of the hash in a stable order, integers and booleans by value and strings alphabetically
+func (h *Hash) SortedKeys() []HashKey {
+	keys := make([]HashKey, 0, len(h.Pairs))
+	for key := range h.Pairs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+
+		if keys[i].Type == StringObj {
+			return keys[i].InspectValue < keys[j].InspectValue
+		}
+
+		return int(keys[i].Value) < int(keys[j].Value)
+	})
+
+	return keys
+}
+
 type IncludeObject struct {
 	Value *Environment
+	Path  string // the included file
 }
 
 func (i *IncludeObject) Type() ObjectType { return IncludeObj }
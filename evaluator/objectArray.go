@@ -199,7 +199,7 @@ var arrayFunctions = map[string]OwnedFunction[*Array]{
 			index := args[0].(*Integer).Value
 
 			if index < 0 || index >= len(this.Value) {
-				return nil, evaluator.newError(node.Token, "index %d out of bounds", index)
+				return nil, evaluator.newError(node, "index %d out of bounds", index)
 			}
 
 			newValue := []Object{}
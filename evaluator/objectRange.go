@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"github.com/joetifa2003/windlang/ast"
+	"github.com/joetifa2003/windlang/value"
+)
+
+// Range is a lazy sequence of integers, it shares the arithmetic of the ranges of the vm
+type Range struct {
+	value.Range
+}
+
+func (r *Range) GetFunction(name string) (*GoFunction, bool) {
+	return GetFunctionFromObject(name, r, rangeFunctions)
+}
+func (r *Range) Type() ObjectType { return RangeObj }
+func (r *Range) Inspect() string  { return r.Range.String() }
+func (r Range) Clone() Object {
+	return &r
+}
+
+// newRange makes a range from the objects of its start and its end, the end is nil for an open range
+func (e *Evaluator) newRange(node ast.Node, start, end Object, inclusive bool) (*Range, *Error) {
+	startInt, startOk := integerValue(start)
+	endInt, endOk := integerValue(end)
+
+	if end == nil {
+		if !startOk {
+			return nil, e.newError(node, "range bounds must be integers, got %s..", start.Type())
+		}
+
+		return &Range{value.Range{Start: startInt, Step: 1, Open: true}}, nil
+	}
+
+	if !startOk || !endOk {
+		return nil, e.newError(node, "range bounds must be integers, got %s..%s", start.Type(), end.Type())
+	}
+
+	return &Range{value.Range{Start: startInt, End: endInt, Step: 1, Inclusive: inclusive}}, nil
+}
+
+var rangeFunctions = map[string]OwnedFunction[*Range]{
+	"len": {
+		ArgsCount: 0,
+		ArgsTypes: []ObjectType{},
+		Fn: func(evaluator *Evaluator, node *ast.CallExpression, this *Range, args ...Object) (Object, *Error) {
+			if this.Open {
+				return nil, evaluator.newError(node, "an open range has no length")
+			}
+
+			return Integer{Value: this.Len()}, nil
+		},
+	},
+	"contains": {
+		ArgsCount: 1,
+		ArgsTypes: []ObjectType{Any},
+		Fn: func(evaluator *Evaluator, node *ast.CallExpression, this *Range, args ...Object) (Object, *Error) {
+			n, ok := integerValue(args[0])
+
+			return boolToBoolObject(ok && this.Contains(n)), nil
+		},
+	},
+	"step": {
+		ArgsCount: 1,
+		ArgsTypes: []ObjectType{IntegerObj},
+		Fn: func(evaluator *Evaluator, node *ast.CallExpression, this *Range, args ...Object) (Object, *Error) {
+			step, _ := integerValue(args[0])
+			if step == 0 {
+				return nil, evaluator.newError(node, "the step of a range cannot be 0")
+			}
+
+			stepped := *this
+			stepped.Step = step
+
+			return &stepped, nil
+		},
+	},
+	"toArray": {
+		ArgsCount: 0,
+		ArgsTypes: []ObjectType{},
+		Fn: func(evaluator *Evaluator, node *ast.CallExpression, this *Range, args ...Object) (Object, *Error) {
+			if this.Open {
+				return nil, evaluator.newError(node, "an open range cannot be converted to an array")
+			}
+
+			n := this.Len()
+			if err := evaluator.budget.ArraySize(n); err != nil {
+				return nil, evaluator.newFatalError(node, err)
+			}
+
+			elements := make([]Object, n)
+			for i := range elements {
+				elements[i] = Integer{Value: this.Start + i*this.Step}
+			}
+
+			return &Array{Value: elements}, nil
+		},
+	},
+}
@@ -172,11 +172,11 @@ var stringFunctions = map[string]OwnedFunction[*String]{
 			newValue := args[1].(*String)
 
 			if index.Value >= len(this.Value) {
-				return nil, evaluator.newError(node.Token, "index out of range: got %d max %d", index.Value, len(this.Value)-1)
+				return nil, evaluator.newError(node, "index out of range: got %d max %d", index.Value, len(this.Value)-1)
 			}
 
 			if len(newValue.Value) > 1 {
-				return nil, evaluator.newError(node.Token, "new value can be at most one character")
+				return nil, evaluator.newError(node, "new value can be at most one character")
 			}
 
 			return &String{
@@ -0,0 +1,571 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode"
+
+	"github.com/joetifa2003/windlang/ast"
+)
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// errOutOfRange is wrapped by the conversions of numbers the other side can't hold exactly
+var errOutOfRange = errors.New("out of range")
+
+// ToObject converts a go value to a Wind value using reflection.
+// Numbers, strings, booleans, slices, maps and pointers are converted to the matching Wind values,
+// functions become builtin functions (see NewGoFunction) and structs become hashes
+// of their exported fields and methods (see NewGoObject). Wind values are returned as is
+func ToObject(v interface{}) (Object, error) {
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (Object, error) {
+	if v.IsValid() && v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return NIL, nil
+		}
+
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return NIL, nil
+
+	case reflect.Bool:
+		return boolToBoolObject(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Integer{Value: int(v.Int())}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return nil, fmt.Errorf("cannot convert %d to a Wind integer: %w", v.Uint(), errOutOfRange)
+		}
+
+		return Integer{Value: int(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			elements[i] = element
+		}
+
+		return &Array{Value: elements}, nil
+
+	case reflect.Map:
+		pairs := make(map[HashKey]Object, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Inspect())
+			}
+
+			value, err := toObject(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+
+			pairs[hashable.HashKey()] = value
+		}
+
+		return &Hash{Pairs: pairs}, nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return NIL, nil
+		}
+
+		if v.Elem().Kind() == reflect.Struct {
+			return newGoObject(v), nil
+		}
+
+		return toObject(v.Elem())
+
+	case reflect.Interface:
+		return toObject(v.Elem())
+
+	case reflect.Struct:
+		// copied so the methods with a pointer receiver can be called
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+
+		return newGoObject(ptr), nil
+
+	case reflect.Func:
+		if v.IsNil() {
+			return NIL, nil
+		}
+
+		return newGoFunction(v), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Wind value", v.Type())
+}
+
+// ToInterface converts a Wind value to the go value it's most like, integers become int,
+// floats float64, arrays []interface{} and hashes map[string]interface{}.
+// Functions are returned as is and can be passed back to Wind
+func ToInterface(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case *Nil:
+		return nil
+	case *Boolean:
+		return obj.Value
+	case Integer:
+		return obj.Value
+	case *Integer:
+		return obj.Value
+	case *Float:
+		return obj.Value
+	case *String:
+		return obj.Value
+
+	case *Array:
+		elements := make([]interface{}, len(obj.Value))
+		for i, element := range obj.Value {
+			elements[i] = ToInterface(element)
+		}
+
+		return elements
+
+	case *Hash:
+		pairs := make(map[string]interface{}, len(obj.Pairs))
+		for key, element := range obj.Pairs {
+			pairs[hashKeyObject(key).Inspect()] = ToInterface(element)
+		}
+
+		return pairs
+	}
+
+	return obj
+}
+
+// NewGoFunction makes a builtin function out of a go function.
+// The arguments are converted to the types of the parameters, a function that
+// returns an error as its last result fails with the error's message when it's not nil,
+// the other results are returned as is if there is one of them or as an array if there are more
+func NewGoFunction(fn interface{}) (*GoFunction, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("expected a function got %T", fn)
+	}
+
+	return newGoFunction(v), nil
+}
+
+func newGoFunction(fn reflect.Value) *GoFunction {
+	t := fn.Type()
+
+	argsCount := t.NumIn()
+	if t.IsVariadic() {
+		argsCount = -1
+	}
+
+	return &GoFunction{
+		ArgsCount: argsCount,
+		Fn: func(evaluator *Evaluator, node *ast.CallExpression, args ...Object) (result Object, err *Error) {
+			if t.IsVariadic() && len(args) < t.NumIn()-1 {
+				return nil, evaluator.newError(node, "expected at least %d arg(s) got %d", t.NumIn()-1, len(args))
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var paramType reflect.Type
+				if t.IsVariadic() && i >= t.NumIn()-1 {
+					paramType = t.In(t.NumIn() - 1).Elem()
+				} else {
+					paramType = t.In(i)
+				}
+
+				value, convErr := evaluator.fromObject(arg, paramType)
+				if errors.Is(convErr, errOutOfRange) {
+					return nil, evaluator.newError(node, "arg %d: %s", i, convErr.Error())
+				}
+
+				if convErr != nil {
+					return nil, evaluator.newError(node, "expected arg %d to be of type %s got %s", i, paramType, arg.Type())
+				}
+
+				in[i] = value
+			}
+
+			// Wind functions passed as callbacks panic with their error, it's returned as the error of the call
+			defer func() {
+				if r := recover(); r != nil {
+					callbackErr, ok := r.(*Error)
+					if !ok {
+						panic(r)
+					}
+
+					result, err = nil, callbackErr
+				}
+			}()
+
+			out := fn.Call(in)
+
+			if len(out) != 0 && t.Out(len(out)-1) == errorType {
+				if goErr := out[len(out)-1]; !goErr.IsNil() {
+					return nil, evaluator.newError(node, "%s", goErr.Interface().(error).Error())
+				}
+
+				out = out[:len(out)-1]
+			}
+
+			switch len(out) {
+			case 0:
+				return NIL, nil
+
+			case 1:
+				obj, convErr := toObject(out[0])
+				if convErr != nil {
+					return nil, evaluator.newError(node, "%s", convErr.Error())
+				}
+
+				return obj, nil
+			}
+
+			elements := make([]Object, len(out))
+			for i, value := range out {
+				obj, convErr := toObject(value)
+				if convErr != nil {
+					return nil, evaluator.newError(node, "%s", convErr.Error())
+				}
+
+				elements[i] = obj
+			}
+
+			return &Array{Value: elements}, nil
+		},
+	}
+}
+
+// NewGoObject makes a hash out of a struct or a pointer to a struct, the hash holds a copy of the exported fields
+// and the exported methods as builtin functions, fields that can't be converted are left out.
+// Go names are written in camel case like Wind's own functions, Name becomes name and HTTPGet becomes httpGet
+func NewGoObject(v interface{}) (*Hash, error) {
+	ptr := reflect.ValueOf(v)
+	switch {
+	case ptr.Kind() == reflect.Struct:
+		copied := reflect.New(ptr.Type())
+		copied.Elem().Set(ptr)
+		ptr = copied
+
+	case ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct:
+		return nil, fmt.Errorf("expected a struct got %T", v)
+	}
+
+	return newGoObject(ptr), nil
+}
+
+func newGoObject(ptr reflect.Value) *Hash {
+	pairs := map[HashKey]Object{}
+	set := func(name string, obj Object) {
+		key := &String{Value: windName(name)}
+		pairs[key.HashKey()] = obj
+	}
+
+	elem := ptr.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		obj, err := toObject(elem.Field(i))
+		if err != nil {
+			continue
+		}
+
+		set(field.Name, obj)
+	}
+
+	for i := 0; i < ptr.NumMethod(); i++ {
+		set(ptr.Type().Method(i).Name, newGoFunction(ptr.Method(i)))
+	}
+
+	return &Hash{Pairs: pairs}
+}
+
+// fromObject converts a Wind value to a go value of the given type, Wind functions
+// are converted to go functions that call them with this evaluator
+func (e *Evaluator) fromObject(obj Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	if obj.Type() == NilObj {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	mismatch := fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return reflect.Value{}, mismatch
+		}
+
+		return reflect.ValueOf(ToInterface(obj)), nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := integerValue(obj)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		value := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value.OverflowInt(int64(i)) {
+				return reflect.Value{}, fmt.Errorf("cannot use %d as %s: %w", i, t, errOutOfRange)
+			}
+
+			value.SetInt(int64(i))
+
+		default:
+			if i < 0 || value.OverflowUint(uint64(i)) {
+				return reflect.Value{}, fmt.Errorf("cannot use %d as %s: %w", i, t, errOutOfRange)
+			}
+
+			value.SetUint(uint64(i))
+		}
+
+		return value, nil
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if n, ok := obj.(*Float); ok {
+			f = n.Value
+		} else if i, ok := integerValue(obj); ok {
+			// integers above 2^53 have no exact float
+			f = float64(i)
+			if int(f) != i {
+				return reflect.Value{}, fmt.Errorf("cannot use %d as %s: %w", i, t, errOutOfRange)
+			}
+		} else {
+			return reflect.Value{}, mismatch
+		}
+
+		value := reflect.New(t).Elem()
+		if value.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("cannot use %g as %s: %w", f, t, errOutOfRange)
+		}
+
+		value.SetFloat(f)
+
+		return value, nil
+
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+
+	case reflect.Slice:
+		if array, ok := obj.(*Array); ok {
+			slice := reflect.MakeSlice(t, len(array.Value), len(array.Value))
+			for i, element := range array.Value {
+				value, err := e.fromObject(element, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				slice.Index(i).Set(value)
+			}
+
+			return slice, nil
+		}
+
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+			for key, element := range hash.Pairs {
+				k, err := e.fromObject(hashKeyObject(key), t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				value, err := e.fromObject(element, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				m.SetMapIndex(k, value)
+			}
+
+			return m, nil
+		}
+
+	case reflect.Pointer:
+		value, err := e.fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(value)
+
+		return ptr, nil
+
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			s := reflect.New(t).Elem()
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if !field.IsExported() {
+					continue
+				}
+
+				key := &String{Value: windName(field.Name)}
+				element, ok := hash.Pairs[key.HashKey()]
+				if !ok {
+					continue
+				}
+
+				value, err := e.fromObject(element, field.Type)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				s.Field(i).Set(value)
+			}
+
+			return s, nil
+		}
+
+	case reflect.Func:
+		if obj.Type() == FunctionObj || obj.Type() == BuiltinObj {
+			return e.goFunction(obj, t), nil
+		}
+	}
+
+	return reflect.Value{}, mismatch
+}
+
+// goFunction makes a go function of the given type that calls a Wind function,
+// if the call fails the error is returned when the function returns an error and it panics otherwise.
+// Errors that stop the script, like going over its limits, always panic so the go code can't swallow them
+func (e *Evaluator) goFunction(fn Object, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+
+		fail := func(err *Error) []reflect.Value {
+			if !err.Fatal() && len(out) != 0 && t.Out(len(out)-1) == errorType {
+				out[len(out)-1] = reflect.ValueOf(&err).Elem().Convert(errorType)
+				return out
+			}
+
+			panic(err)
+		}
+
+		args := make([]Object, len(in))
+		for i, value := range in {
+			arg, err := toObject(value)
+			if err != nil {
+				return fail(&Error{Message: err.Error(), File: e.filePath})
+			}
+
+			args[i] = arg
+		}
+
+		result, err := e.CallFunction(fn, args...)
+		if err != nil {
+			return fail(err)
+		}
+
+		if len(out) != 0 && t.Out(0) != errorType {
+			value, convErr := e.fromObject(result, t.Out(0))
+			if convErr != nil {
+				return fail(&Error{Message: convErr.Error(), File: e.filePath})
+			}
+
+			out[0] = value
+		}
+
+		return out
+	})
+}
+
+// integerValue returns the value of an integer, the builtins return integers as pointers, ok is false for the other types
+func integerValue(obj Object) (value int, ok bool) {
+	switch i := obj.(type) {
+	case Integer:
+		return i.Value, true
+	case *Integer:
+		return i.Value, true
+	}
+
+	return 0, false
+}
+
+// hashKeyObject returns the value a hash key was made from
+func hashKeyObject(key HashKey) Object {
+	switch key.Type {
+	case IntegerObj:
+		return Integer{Value: int(key.Value)}
+	case BooleanObj:
+		return boolToBoolObject(key.Value == 1)
+	}
+
+	return &String{Value: key.InspectValue}
+}
+
+// windName lowers the leading capitals of a go name, keeping the last one if it starts a word
+func windName(name string) string {
+	runes := []rune(name)
+
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+
+	if i > 1 && i < len(runes) {
+		i--
+	}
+
+	for j := 0; j < i; j++ {
+		runes[j] = unicode.ToLower(runes[j])
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counter struct {
+	Name  string
+	Count int
+	hits  int
+}
+
+func (c *counter) Add(n int) int {
+	c.Count += n
+	return c.Count
+}
+
+func (c counter) HTTPStatus() string {
+	return c.Name + " ok"
+}
+
+func evalWithGlobals(t *testing.T, input string, globals map[string]interface{}) (Object, *Error) {
+	env := NewEnvironment()
+	for name, v := range globals {
+		obj, err := ToObject(v)
+		assert.Nil(t, err)
+
+		env.Let(name, obj)
+	}
+
+	p := parser.New(lexer.New(input), "test.wind")
+	program := p.ParseProgram()
+	assert.Empty(t, p.ReportErrors())
+
+	return New(NewEnvironmentManager(), "test.wind").Eval(program, env, nil)
+}
+
+func TestGoFunctions(t *testing.T) {
+	globals := map[string]interface{}{
+		"upper": strings.ToUpper,
+		"sum": func(nums ...float64) float64 {
+			s := 0.0
+			for _, n := range nums {
+				s += n
+			}
+			return s
+		},
+		"divide": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+		"pair":  func() (string, bool) { return "a", true },
+		"keys":  func(m map[string]int) int { return len(m) },
+		"apply": func(f func(int) int, x int) int { return f(x) },
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`upper("wind")`, "WIND"},
+		{`sum(1, 2.5)`, 3.5},
+		{`divide(7, 2)`, 3},
+		{`pair()`, []interface{}{"a", true}},
+		{`keys({"a": 1, "b": 2})`, 2},
+		{`apply(fn(x) { x * 10 }, 4)`, 40},
+		{`try { divide(1, 0) } catch (e) { e["message"] }`, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		result, err := evalWithGlobals(t, tt.input, globals)
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.expected, ToInterface(result), tt.input)
+		}
+	}
+}
+
+func TestGoFunctionErrors(t *testing.T) {
+	globals := map[string]interface{}{
+		"upper":   strings.ToUpper,
+		"apply":   func(f func(int) int, x int) int { return f(x) },
+		"small":   func(n int8) int8 { return n },
+		"count":   func(n uint) uint { return n },
+		"half":    func(n int) int { return n / 2 },
+		"single":  func(f float32) float32 { return f },
+		"precise": func(f float64) float64 { return f },
+		"huge":    func() uint64 { return 1 << 63 },
+		"big":     1e300,
+	}
+
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`upper(1)`, "expected arg 0 to be of type string got INTEGER"},
+		{`upper()`, "expected 1 arg(s) got 0"},
+		{`apply(fn(x) { x + "a" }, 1)`, "unknown operator: 1 + a"},
+		{`small(300)`, "arg 0: cannot use 300 as int8: out of range"},
+		{`count(-1)`, "arg 0: cannot use -1 as uint: out of range"},
+		{`half(1.5)`, "expected arg 0 to be of type int got FLOAT"},
+		{`single(big)`, "arg 0: cannot use 1e+300 as float32: out of range"},
+		{`precise(9007199254740993)`, "arg 0: cannot use 9007199254740993 as float64: out of range"},
+		{`huge()`, "cannot convert 9223372036854775808 to a Wind integer: out of range"},
+	}
+
+	for _, tt := range tests {
+		_, err := evalWithGlobals(t, tt.input, globals)
+		if assert.NotNil(t, err, tt.input) {
+			assert.Equal(t, tt.message, err.Message, tt.input)
+		}
+	}
+}
+
+func TestGoFunctionsKeepFatalErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// the callback's error is ignored, the script must stop anyway
+	calls := 0
+	each := func(f func() error) {
+		for i := 0; i < 3; i++ {
+			calls++
+			_ = f()
+		}
+	}
+
+	obj, err := ToObject(each)
+	assert.Nil(err)
+
+	env := NewEnvironment()
+	env.Let("each", obj)
+
+	p := parser.New(lexer.New(`each(fn() { while (true) {} });`), "test.wind")
+	program := p.ParseProgram()
+	assert.Empty(p.ReportErrors())
+
+	ev := New(NewEnvironmentManager(), "test.wind")
+	ev.SetLimits(context.Background(), sandbox.Limits{MaxSteps: 1000})
+
+	_, evErr := ev.Eval(program, env, nil)
+	if assert.NotNil(evErr) {
+		assert.ErrorIs(evErr, sandbox.ErrLimitExceeded)
+	}
+	assert.Equal(1, calls)
+}
+
+func TestGoObjects(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &counter{Name: "c", Count: 1}
+	result, err := evalWithGlobals(t, `[c["name"], c["add"](2), c["add"](3), c["httpStatus"](), c["hits"]]`, map[string]interface{}{"c": c})
+	if assert.Nil(err) {
+		assert.Equal([]interface{}{"c", 3, 6, "c ok", nil}, ToInterface(result))
+	}
+	assert.Equal(6, c.Count)
+
+	_, convErr := NewGoObject(1)
+	assert.EqualError(convErr, "expected a struct got int")
+
+	_, convErr = ToObject(make(chan int))
+	assert.EqualError(convErr, "cannot convert chan int to a Wind value")
+}
+
+func TestWindName(t *testing.T) {
+	for name, expected := range map[string]string{"Name": "name", "HTTPGet": "httpGet", "ID": "id", "A": "a", "ToURL": "toURL"} {
+		assert.Equal(t, expected, windName(name))
+	}
+}
@@ -9,6 +9,9 @@ func GetStdlib(filePath string) (*Environment, bool) {
 
 	case "request":
 		return getLibrary("request", stdLibReq), true
+
+	case "fs":
+		return getLibrary("fs", stdLibFs), true
 	}
 
 	return nil, false
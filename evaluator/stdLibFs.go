@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"os"
+
+	"github.com/joetifa2003/windlang/ast"
+)
+
+func stdLibFs() *Environment {
+	return &Environment{
+		Store: map[string]Object{
+			"readFile": &GoFunction{
+				ArgsCount: 1,
+				ArgsTypes: []ObjectType{StringObj},
+				Fn: func(evaluator *Evaluator, node *ast.CallExpression, args ...Object) (Object, *Error) {
+					path := args[0].(*String).Value
+					if err := evaluator.permissions.CheckFile(path); err != nil {
+						return NIL, evaluator.newError(node, "%s", err.Error())
+					}
+
+					content, err := os.ReadFile(path)
+					if err != nil {
+						return NIL, evaluator.newError(node, "cannot read file: %s", path)
+					}
+
+					return &String{Value: string(content)}, nil
+				},
+			},
+			"writeFile": &GoFunction{
+				ArgsCount: 2,
+				ArgsTypes: []ObjectType{StringObj, StringObj},
+				Fn: func(evaluator *Evaluator, node *ast.CallExpression, args ...Object) (Object, *Error) {
+					path := args[0].(*String).Value
+					if err := evaluator.permissions.CheckFile(path); err != nil {
+						return NIL, evaluator.newError(node, "%s", err.Error())
+					}
+
+					if err := os.WriteFile(path, []byte(args[1].(*String).Value), 0644); err != nil {
+						return NIL, evaluator.newError(node, "cannot write file: %s", path)
+					}
+
+					return NIL, nil
+				},
+			},
+		},
+	}
+}
@@ -16,15 +16,18 @@ func stdLibReq() *Environment {
 				ArgsTypes: []ObjectType{StringObj},
 				Fn: func(evaluator *Evaluator, node *ast.CallExpression, args ...Object) (Object, *Error) {
 					url := args[0].(*String)
+					if err := evaluator.permissions.CheckNet(url.Value); err != nil {
+						return NIL, evaluator.newError(node, "%s", err.Error())
+					}
 
 					resp, err := http.Get(url.Value)
 					if err != nil {
-						return NIL, evaluator.newError(node.Token, "get request failed")
+						return NIL, evaluator.newError(node, "get request failed")
 					}
 
 					respBytes, err := ioutil.ReadAll(resp.Body)
 					if err != nil {
-						return NIL, evaluator.newError(node.Token, "get request failed")
+						return NIL, evaluator.newError(node, "get request failed")
 					}
 
 					result := make(map[string]interface{})
@@ -1,17 +1,39 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/joetifa2003/windlang/token"
 )
 
+// Error is a diagnostic about a part of the source that isn't a valid token,
+// the lexer returns an ILLEGAL token for it
+type Error struct {
+	Token token.Token
+	Msg   string
+}
+
 type Lexer struct {
 	input        []rune
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           rune // current char under examination
-	Line         int
+	Line         int  // line of the current char
+	Column       int  // column of the current char
+
+	// templates are the strings whose interpolation is being read, innermost last
+	templates []template
+
+	Errors []Error
+}
+
+// template is a string with an interpolation being read, the string continues at the } that closes it
+type template struct {
+	line   int // line the string starts at
+	braces int // braces opened inside the interpolation
 }
 
 func New(input string) *Lexer {
@@ -21,7 +43,19 @@ func New(input string) *Lexer {
 	return &l
 }
 
+// Source returns the input the lexer was created with
+func (l *Lexer) Source() string {
+	return string(l.input)
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.Line++
+		l.Column = 1
+	} else {
+		l.Column++
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -37,6 +71,8 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	start := l.currentPosition()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -51,7 +87,7 @@ func (l *Lexer) NextToken() token.Token {
 
 			tok = token.Token{Type: token.AND, Literal: "&&"}
 		} else {
-			tok = token.Token{Type: token.ILLEGAL, Literal: string(l.ch) + string(l.peekChar())}
+			tok = l.newToken(token.ILLEGAL, l.ch)
 		}
 	case '|':
 		if l.peekChar() == '|' {
@@ -59,7 +95,7 @@ func (l *Lexer) NextToken() token.Token {
 
 			tok = token.Token{Type: token.OR, Literal: "||"}
 		} else {
-			tok = token.Token{Type: token.ILLEGAL, Literal: string(l.ch) + string(l.peekChar())}
+			tok = l.newToken(token.ILLEGAL, l.ch)
 		}
 	case ';':
 		tok = l.newToken(token.SEMICOLON, l.ch)
@@ -70,16 +106,32 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = l.newToken(token.COMMA, l.ch)
 	case '%':
-		tok = l.newToken(token.MODULO, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MODULO_ASSIGN, Literal: "%="}
+		} else {
+			tok = l.newToken(token.MODULO, l.ch)
+		}
 	case '+':
 		if l.peekChar() == '+' {
 			l.readChar()
 			tok = token.Token{Type: token.PLUSPLUS, Literal: "++"}
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: "+="}
 		} else {
 			tok = l.newToken(token.PLUS, l.ch)
 		}
 	case '-':
-		tok = l.newToken(token.MINUS, l.ch)
+		if l.peekChar() == '-' {
+			l.readChar()
+			tok = token.Token{Type: token.MINUSMINUS, Literal: "--"}
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: "-="}
+		} else {
+			tok = l.newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -88,7 +140,12 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = l.newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.ASTERISK_ASSIGN, Literal: "*="}
+		} else {
+			tok = l.newToken(token.ASTERISK, l.ch)
+		}
 	case '/':
 		if l.peekChar() == '/' {
 			l.readChar()
@@ -99,6 +156,9 @@ func (l *Lexer) NextToken() token.Token {
 			}
 
 			return l.NextToken()
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: "/="}
 		} else {
 			tok = l.newToken(token.SLASH, l.ch)
 		}
@@ -119,39 +179,76 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.newToken(token.GT, l.ch)
 		}
 	case '{':
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1].braces++
+		}
+
 		tok = l.newToken(token.LBRACE, l.ch)
 	case '}':
+		if len(l.templates) > 0 && l.templates[len(l.templates)-1].braces == 0 {
+			line := l.templates[len(l.templates)-1].line
+			l.templates = l.templates[:len(l.templates)-1]
+
+			return l.readStringToken(start, line)
+		}
+
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1].braces--
+		}
+
 		tok = l.newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = l.newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = l.newToken(token.RBRACKET, l.ch)
 	case '"':
+		return l.readStringToken(start, start.Line)
+	case '`':
+		literal, terminated := l.readRawString()
+		if !terminated {
+			return l.unterminatedString(start, start.Line)
+		}
+
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = literal
 	case ':':
 		tok = l.newToken(token.COLON, l.ch)
 	case '.':
 		if l.peekChar() == '.' {
 			l.readChar()
 
-			tok = token.Token{Type: token.DOTDOT, Literal: ".."}
+			if l.peekChar() == '=' {
+				l.readChar()
+
+				tok = token.Token{Type: token.DOTDOT_EQ, Literal: "..="}
+			} else {
+				tok = token.Token{Type: token.DOTDOT, Literal: ".."}
+			}
 		} else {
 			tok = l.newToken(token.DOT, l.ch)
 		}
 	case 0:
+		if len(l.templates) > 0 {
+			return l.unterminatedString(start, l.templates[0].line)
+		}
+
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
-			tok.Line = l.Line
+			l.setSpan(&tok, start)
 
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal, tok.Type = l.readNumber()
-			tok.Line = l.Line
+			var msg string
+			tok.Literal, tok.Type, msg = l.readNumber()
+			l.setSpan(&tok, start)
+
+			if msg != "" {
+				l.error(tok, msg)
+			}
 
 			return tok
 		} else {
@@ -159,12 +256,33 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
-	l.readChar()
+	if tok.Type != token.EOF {
+		l.readChar()
+	}
+
+	l.setSpan(&tok, start)
+
+	if tok.Type == token.ILLEGAL && len(tok.Literal) != 0 {
+		l.error(tok, fmt.Sprintf("unknown character %#U", []rune(tok.Literal)[0]))
+	}
 
-	tok.Line = l.Line
 	return tok
 }
 
+func (l *Lexer) error(tok token.Token, msg string) {
+	l.Errors = append(l.Errors, Error{Token: tok, Msg: msg})
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Offset: l.position, Line: l.Line, Column: l.Column}
+}
+
+// setSpan sets the span of the token from start to the current char
+func (l *Lexer) setSpan(tok *token.Token, start token.Position) {
+	tok.Line = start.Line
+	tok.Span = token.Span{Start: start, End: l.currentPosition()}
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
@@ -175,45 +293,242 @@ func (l *Lexer) readIdentifier() string {
 	return string(l.input[position:l.position])
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readStringToken reads the part of a string that starts after the current char, the opening quote
+// or the } closing an interpolation. A whole string is a STRING, the parts of a string with
+// interpolations are a TEMPLATE, followed by a TEMPLATE_MIDDLE between each two interpolations
+// and a TEMPLATE_END
+func (l *Lexer) readStringToken(start token.Position, line int) token.Token {
+	continued := l.ch == '}'
+
+	literal, interpolation, terminated := l.readString()
+	if !terminated {
+		return l.unterminatedString(start, line)
+	}
+
+	tok := token.Token{Type: token.STRING, Literal: literal}
+	switch {
+	case interpolation && continued:
+		tok.Type = token.TEMPLATE_MIDDLE
+	case interpolation:
+		tok.Type = token.TEMPLATE
+	case continued:
+		tok.Type = token.TEMPLATE_END
+	}
+
+	if interpolation {
+		l.templates = append(l.templates, template{line: line})
+	}
+
+	l.readChar()
+	l.setSpan(&tok, start)
+
+	return tok
+}
+
+// unterminatedString reports a string the input ended in, the strings whose interpolation
+// it's in are reported with it as the input ended in them too
+func (l *Lexer) unterminatedString(start token.Position, line int) token.Token {
+	l.templates = nil
+
+	tok := token.Token{Type: token.ILLEGAL, Literal: string(l.input[start.Offset:l.position])}
+	l.setSpan(&tok, start)
+	l.error(tok, fmt.Sprintf("unterminated string starting at line %d", line))
+
+	return tok
+}
+
+// readString reads a string up to the closing quote or up to the ${ of an interpolation,
+// terminated is false if the input ended before them
+func (l *Lexer) readString() (str string, interpolation bool, terminated bool) {
+	var out strings.Builder
 
 	for {
 		l.readChar()
 
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch {
+		case l.ch == '"':
+			return out.String(), false, true
+
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+
+			return out.String(), true, true
+
+		case l.ch == '\\':
+			l.readEscape(&out)
+
+		case l.ch == 0:
+			return "", false, false
+
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// escapes are the escape sequences made of a backslash and a single character
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'b':  '\b',
+	'f':  '\f',
+	'a':  '\a',
+	'v':  '\v',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// readEscape writes the character of the escape sequence starting at the current backslash,
+// the current char is left on the last char of the sequence. An invalid sequence is reported
+// and skipped, a backslash at the end of the input is left for readString to report the string
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.currentPosition()
+	switch l.peekChar() {
+	case 0:
+		return
+	case '\n', '\r':
+		l.escapeError(start, "a backslash cannot end a line")
+		return
+	}
+
+	l.readChar()
+
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		return
+	}
+
+	switch l.ch {
+	case 'x':
+		digits := l.readHexDigits(2)
+		if len(digits) != 2 {
+			l.escapeError(start, "\\x is followed by two hex digits")
+			return
+		}
+
+		code, _ := strconv.ParseUint(digits, 16, 32)
+		out.WriteRune(rune(code))
+
+	case 'u':
+		if l.peekChar() != '{' {
+			l.escapeError(start, "\\u is followed by hex digits between braces")
+			return
+		}
+
+		l.readChar()
+		digits := l.readHexDigits(6)
+		if digits == "" || l.peekChar() != '}' {
+			l.escapeError(start, "\\u is followed by hex digits between braces")
+			return
+		}
+
+		l.readChar()
+
+		code, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(code)) {
+			l.escapeError(start, fmt.Sprintf("%U is not a valid character", code))
+			return
 		}
+
+		out.WriteRune(rune(code))
+
+	default:
+		l.escapeError(start, "")
+	}
+}
+
+// readHexDigits reads up to max hex digits following the current char
+func (l *Lexer) readHexDigits(max int) string {
+	var digits strings.Builder
+	for digits.Len() < max && isHexDigit(l.peekChar()) {
+		l.readChar()
+		digits.WriteRune(l.ch)
+	}
+
+	return digits.String()
+}
+
+// escapeError reports the escape sequence from start to the current char, explained by reason if it's not empty
+func (l *Lexer) escapeError(start token.Position, reason string) {
+	tok := token.Token{Type: token.ILLEGAL, Literal: string(l.input[start.Offset : l.position+1]), Line: start.Line}
+	tok.Span = token.Span{
+		Start: start,
+		End:   token.Position{Offset: l.position + 1, Line: l.Line, Column: l.Column + 1},
+	}
+
+	msg := fmt.Sprintf("invalid escape sequence %s", tok.Literal)
+	if reason != "" {
+		msg += ", " + reason
 	}
 
-	return escapeCharacters(string(l.input[position:l.position]))
+	l.error(tok, msg)
+}
+
+// readRawString reads a string up to the closing backtick, the characters are taken as they are
+func (l *Lexer) readRawString() (str string, terminated bool) {
+	position := l.position + 1
+
+	for {
+		l.readChar()
+
+		if l.ch == '`' {
+			return string(l.input[position:l.position]), true
+		}
+
+		if l.ch == 0 {
+			return "", false
+		}
+	}
 }
 
-func (l *Lexer) readNumber() (string, token.TokenType) {
+// readNumber reads an integer or a float, a number that isn't valid is returned
+// as an ILLEGAL token with a message that describes the problem
+func (l *Lexer) readNumber() (literal string, tokenType token.TokenType, msg string) {
 	position := l.position
+	tokenType = token.INT
+
+	l.readDigits()
+
+	// the dot has to be followed by a digit so 1..5 is a range and not a float
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
 
-	dotCount := 0
-	for isDigit(l.ch) || l.ch == '.' {
 		l.readChar()
+		l.readDigits()
+	}
+
+	malformed := false
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' && isDigit(l.peekChar()) {
+		malformed = true
+		l.readChar()
+	}
+
+	literal = string(l.input[position:l.position])
 
-		if l.ch == '.' {
-			dotCount++
+	if malformed {
+		return literal, token.ILLEGAL, fmt.Sprintf("malformed number %s", literal)
+	}
+
+	if tokenType == token.INT {
+		if _, err := strconv.Atoi(literal); err != nil {
+			return literal, token.ILLEGAL, fmt.Sprintf("integer %s is out of range", literal)
 		}
 	}
 
-	if dotCount == 1 {
-		return string(l.input[position:l.position]), token.FLOAT
-	} else {
-		return string(l.input[position:l.position]), token.INT
+	return literal, tokenType, ""
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) {
+		l.readChar()
 	}
 }
 
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		if l.ch == '\n' {
-			l.Line++
-		}
-
 		l.readChar()
 	}
 }
@@ -238,14 +553,6 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func escapeCharacters(str string) string {
-	str = strings.ReplaceAll(str, `\n`, "\n")
-	str = strings.ReplaceAll(str, `\t`, "\t")
-	str = strings.ReplaceAll(str, `\r`, "\r")
-	str = strings.ReplaceAll(str, `\b`, "\b")
-	str = strings.ReplaceAll(str, `\f`, "\f")
-	str = strings.ReplaceAll(str, `\a`, "\a")
-	str = strings.ReplaceAll(str, `\v`, "\v")
-
-	return str
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
 }
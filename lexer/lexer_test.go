@@ -46,6 +46,19 @@ var nextTokenTestCases = []struct {
 			{Type: token.EOF, Literal: "", Line: 1},
 		},
 	},
+	{
+		input: "+= -= *= /= %= ++ --",
+		expectedTokens: []token.Token{
+			{Type: token.PLUS_ASSIGN, Literal: "+=", Line: 1},
+			{Type: token.MINUS_ASSIGN, Literal: "-=", Line: 1},
+			{Type: token.ASTERISK_ASSIGN, Literal: "*=", Line: 1},
+			{Type: token.SLASH_ASSIGN, Literal: "/=", Line: 1},
+			{Type: token.MODULO_ASSIGN, Literal: "%=", Line: 1},
+			{Type: token.PLUSPLUS, Literal: "++", Line: 1},
+			{Type: token.MINUSMINUS, Literal: "--", Line: 1},
+			{Type: token.EOF, Literal: "", Line: 1},
+		},
+	},
 	{
 		input: `true false 1 3.14 "hello" x`,
 		expectedTokens: []token.Token{
@@ -68,7 +81,200 @@ func TestNextToken(t *testing.T) {
 
 		for _, expectedToken := range testCase.expectedTokens {
 			actualToken := lexer.NextToken()
+			actualToken.Span = token.Span{} // spans are covered by TestTokenSpans
 			assert.Equal(expectedToken, actualToken)
 		}
 	}
 }
+
+func TestTokenSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	input := "let x = 10;\n\tx == \"hi\""
+
+	expectedSpans := []token.Span{
+		{Start: token.Position{Offset: 0, Line: 1, Column: 1}, End: token.Position{Offset: 3, Line: 1, Column: 4}},
+		{Start: token.Position{Offset: 4, Line: 1, Column: 5}, End: token.Position{Offset: 5, Line: 1, Column: 6}},
+		{Start: token.Position{Offset: 6, Line: 1, Column: 7}, End: token.Position{Offset: 7, Line: 1, Column: 8}},
+		{Start: token.Position{Offset: 8, Line: 1, Column: 9}, End: token.Position{Offset: 10, Line: 1, Column: 11}},
+		{Start: token.Position{Offset: 10, Line: 1, Column: 11}, End: token.Position{Offset: 11, Line: 1, Column: 12}},
+		{Start: token.Position{Offset: 13, Line: 2, Column: 2}, End: token.Position{Offset: 14, Line: 2, Column: 3}},
+		{Start: token.Position{Offset: 15, Line: 2, Column: 4}, End: token.Position{Offset: 17, Line: 2, Column: 6}},
+		{Start: token.Position{Offset: 18, Line: 2, Column: 7}, End: token.Position{Offset: 22, Line: 2, Column: 11}},
+		{Start: token.Position{Offset: 22, Line: 2, Column: 11}, End: token.Position{Offset: 22, Line: 2, Column: 11}},
+	}
+
+	lexer := New(input)
+	for _, expectedSpan := range expectedSpans {
+		tok := lexer.NextToken()
+		assert.Equal(expectedSpan, tok.Span, tok.Literal)
+		assert.Equal(expectedSpan.Start.Line, tok.Line)
+	}
+}
+
+func TestSpanHighlight(t *testing.T) {
+	assert := assert.New(t)
+
+	input := "let x = 1;\n\tlet y = x + z;"
+	lexer := New(input)
+
+	var tok token.Token
+	for tok = lexer.NextToken(); tok.Literal != "z"; tok = lexer.NextToken() {
+	}
+
+	assert.Equal("2 | \tlet y = x + z;\n  | \t            ^", tok.Span.Highlight(input))
+}
+
+func TestNumbers(t *testing.T) {
+	assert := assert.New(t)
+
+	lexer := New("1..5 2.5 7 0..=3")
+	expectedTokens := []token.Token{
+		{Type: token.INT, Literal: "1"},
+		{Type: token.DOTDOT, Literal: ".."},
+		{Type: token.INT, Literal: "5"},
+		{Type: token.FLOAT, Literal: "2.5"},
+		{Type: token.INT, Literal: "7"},
+		{Type: token.INT, Literal: "0"},
+		{Type: token.DOTDOT_EQ, Literal: "..="},
+		{Type: token.INT, Literal: "3"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	for _, expected := range expectedTokens {
+		tok := lexer.NextToken()
+		assert.Equal(expected.Type, tok.Type, tok.Literal)
+		assert.Equal(expected.Literal, tok.Literal)
+	}
+
+	assert.Empty(lexer.Errors)
+}
+
+func TestTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	lexer := New("\"a ${x} b ${ {1: \"${y}\"} } c\" `raw ${x} \\n`")
+	expectedTokens := []token.Token{
+		{Type: token.TEMPLATE, Literal: "a "},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.TEMPLATE_MIDDLE, Literal: " b "},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.COLON, Literal: ":"},
+		{Type: token.TEMPLATE, Literal: ""},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.TEMPLATE_END, Literal: ""},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.TEMPLATE_END, Literal: " c"},
+		{Type: token.STRING, Literal: "raw ${x} \\n"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	for _, expected := range expectedTokens {
+		tok := lexer.NextToken()
+		assert.Equal(expected.Type, tok.Type, tok.Literal)
+		assert.Equal(expected.Literal, tok.Literal)
+	}
+
+	assert.Empty(lexer.Errors)
+
+	lexer = New("\"a ${x")
+	lexer.NextToken()
+	lexer.NextToken()
+
+	assert.Equal(token.ILLEGAL, lexer.NextToken().Type)
+	assert.Equal(token.EOF, lexer.NextToken().Type)
+	if assert.Len(lexer.Errors, 1) {
+		assert.Equal("unterminated string starting at line 1", lexer.Errors[0].Msg)
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\tb\nc"`, "a\tb\nc"},
+		{`"\\n"`, `\n`},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"\x41\x7a"`, "Az"},
+		{`"\u{1F600} \u{e9}"`, "😀 é"},
+		{`"\${x}"`, "${x}"},
+		{`"\r\b\f\a\v\0"`, "\r\b\f\a\v\x00"},
+	}
+
+	for _, tc := range tests {
+		lexer := New(tc.input)
+
+		tok := lexer.NextToken()
+		assert.Equal(token.STRING, tok.Type, tc.input)
+		assert.Equal(tc.expected, tok.Literal, tc.input)
+		assert.Empty(lexer.Errors, tc.input)
+	}
+}
+
+func TestEscapeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input   string
+		literal string
+		msg     string
+	}{
+		{`"\q"`, `\q`, `invalid escape sequence \q`},
+		{`"\x4"`, `\x4`, `invalid escape sequence \x4, \x is followed by two hex digits`},
+		{`"\u41"`, `\u`, `invalid escape sequence \u, \u is followed by hex digits between braces`},
+		{`"\u{41"`, `\u{41`, `invalid escape sequence \u{41, \u is followed by hex digits between braces`},
+		{`"\u{110000}"`, `\u{110000}`, `invalid escape sequence \u{110000}, U+110000 is not a valid character`},
+		{"\"a\\\nb\"", `\`, `invalid escape sequence \, a backslash cannot end a line`},
+	}
+
+	for _, tc := range tests {
+		lexer := New(tc.input)
+
+		assert.Equal(token.STRING, lexer.NextToken().Type, tc.input)
+		assert.Equal(token.EOF, lexer.NextToken().Type, tc.input)
+
+		if assert.Len(lexer.Errors, 1, tc.input) {
+			assert.Equal(tc.msg, lexer.Errors[0].Msg, tc.input)
+			assert.Equal(tc.literal, lexer.Errors[0].Token.Literal, tc.input)
+
+			span := lexer.Errors[0].Token.Span
+			assert.Equal(tc.literal, string([]rune(tc.input)[span.Start.Offset:span.End.Offset]), tc.input)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input   string
+		literal string
+		msg     string
+	}{
+		{"1.2.3", "1.2.3", "malformed number 1.2.3"},
+		{"12ab", "12ab", "malformed number 12ab"},
+		{"99999999999999999999", "99999999999999999999", "integer 99999999999999999999 is out of range"},
+		{"@", "@", "unknown character U+0040 '@'"},
+		{"&", "&", "unknown character U+0026 '&'"},
+		{"\n\"abc\n", "\"abc\n", "unterminated string starting at line 2"},
+		{"`abc", "`abc", "unterminated string starting at line 1"},
+	}
+
+	for _, tc := range tests {
+		lexer := New(tc.input)
+
+		tok := lexer.NextToken()
+		assert.Equal(token.ILLEGAL, tok.Type, tc.input)
+		assert.Equal(tc.literal, tok.Literal, tc.input)
+		assert.Equal(token.EOF, lexer.NextToken().Type, tc.input)
+
+		if assert.Len(lexer.Errors, 1, tc.input) {
+			assert.Equal(tc.msg, lexer.Errors[0].Msg)
+			assert.Equal(tok, lexer.Errors[0].Token)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package loader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Include is an include statement that is being evaluated, the file with the statement and its line
+type Include struct {
+	File string
+	Line int
+}
+
+// Cycle reports whether including path after the includes of the stack includes a file that is still being included,
+// the chain of includes is returned like "a.wind:1 -> b.wind:3 -> a.wind".
+// The paths are cleaned before they are compared, so an entry file given as ./a.wind is the a.wind its includes resolve to
+func Cycle(stack []Include, path string) (chain string, ok bool) {
+	path = filepath.Clean(path)
+
+	for i, include := range stack {
+		if filepath.Clean(include.File) != path {
+			continue
+		}
+
+		var out strings.Builder
+		for _, include := range stack[i:] {
+			fmt.Fprintf(&out, "%s:%d -> ", filepath.Clean(include.File), include.Line)
+		}
+		out.WriteString(path)
+
+		return out.String(), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,189 @@
+// Package loader finds and reads the files included by Wind scripts
+package loader
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Extension is added to the include paths that don't name a file
+	Extension = ".wind"
+	// IndexFile is included when an include path names a directory
+	IndexFile = "index.wind"
+	// ModFile marks the root directory of a project, the includes that are not relative are searched from it
+	ModFile = "wind.mod"
+	// PathEnv is the environment variable with the directories searched for includes, separated like PATH
+	PathEnv = "WINDPATH"
+)
+
+// ModuleLoader resolves the paths of the includes and loads the source of the included files
+type ModuleLoader interface {
+	// Resolve returns the path of the file included with path by the file from,
+	// the path is used to load the file and to identify it so each file is evaluated once
+	Resolve(from string, path string) (string, error)
+	// Load returns the source of a path returned by Resolve
+	Load(path string) (string, error)
+}
+
+// OS loads the files from the disk. Relative includes are relative to the directory
+// of the file including them, the other paths are searched in the root of the project
+// of the file including them, then in the search path and then in the working directory.
+// An include path can leave out the extension of the file or name a directory with an index file
+type OS struct {
+	SearchPath []string // the directories searched for includes after the project root
+}
+
+// NewOS returns a loader that searches the directories of the WINDPATH environment variable
+func NewOS() *OS {
+	return &OS{SearchPath: filepath.SplitList(os.Getenv(PathEnv))}
+}
+
+func (l *OS) Resolve(from string, path string) (string, error) {
+	if isRelative(path) {
+		return l.find(filepath.Join(filepath.Dir(from), path)), nil
+	}
+
+	if filepath.IsAbs(path) {
+		return l.find(path), nil
+	}
+
+	dirs := l.SearchPath
+	if root, ok := ProjectRoot(filepath.Dir(from)); ok {
+		dirs = append([]string{root}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		if file, ok := l.lookup(filepath.Join(dir, path)); ok {
+			return shorten(file), nil
+		}
+	}
+
+	return l.find(path), nil
+}
+
+func (l *OS) Load(path string) (string, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(file), nil
+}
+
+// find returns the file the path names, or the path itself if there is none
+func (l *OS) find(path string) string {
+	if file, ok := l.lookup(path); ok {
+		return file
+	}
+
+	return path
+}
+
+func (l *OS) lookup(path string) (string, bool) {
+	return lookup(path, filepath.Join, func(file string) bool {
+		info, err := os.Stat(file)
+		return err == nil && !info.IsDir()
+	})
+}
+
+// ProjectRoot returns the closest directory to dir, dir included, that has a wind.mod file
+func ProjectRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ModFile)); err == nil && !info.IsDir() {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
+
+// shorten returns the path relative to the working directory if it's inside of it,
+// so a file found in the project root has the same path as when it's included relatively
+func shorten(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return rel
+}
+
+// FS loads the files from a file system like an embed.FS, a zip archive or an fstest.MapFS.
+// Relative includes are relative to the directory of the file including them and
+// other paths are relative to the root of the file system, like with OS an include path
+// can leave out the extension of the file or name a directory with an index file
+type FS struct {
+	fsys fs.FS
+}
+
+func NewFS(fsys fs.FS) *FS {
+	return &FS{fsys: fsys}
+}
+
+func (l *FS) Resolve(from string, name string) (string, error) {
+	if isRelative(name) {
+		name = path.Join(path.Dir(filepath.ToSlash(from)), name)
+	}
+
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrInvalid}
+	}
+
+	file, ok := lookup(name, path.Join, func(file string) bool {
+		info, err := fs.Stat(l.fsys, file)
+		return err == nil && !info.IsDir()
+	})
+	if !ok {
+		return name, nil
+	}
+
+	return file, nil
+}
+
+func (l *FS) Load(name string) (string, error) {
+	file, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	return string(file), nil
+}
+
+// lookup returns the first file that exists out of the path, the path with the extension
+// and the index file of the directory of the path
+func lookup(path string, join func(elem ...string) string, exists func(file string) bool) (string, bool) {
+	for _, file := range []string{path, path + Extension, join(path, IndexFile)} {
+		if exists(file) {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+// isRelative reports whether the include path is relative to the file including it
+func isRelative(path string) bool {
+	return path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`)
+}
@@ -0,0 +1,138 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOS(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		from     string
+		path     string
+		expected string
+	}{
+		{"main.wind", "./lib.wind", "lib.wind"},
+		{"scripts/main.wind", "./lib.wind", filepath.Join("scripts", "lib.wind")},
+		{"scripts/main.wind", "../lib.wind", "lib.wind"},
+		{"scripts/main.wind", "lib/util.wind", "lib/util.wind"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := NewOS().Resolve(tt.from, tt.path)
+		assert.Nil(err)
+		assert.Equal(tt.expected, resolved, tt.path)
+	}
+}
+
+func TestOSSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	project := t.TempDir()
+	shared := t.TempDir()
+	files := []string{
+		filepath.Join(project, ModFile),
+		filepath.Join(project, "app", "main.wind"),
+		filepath.Join(project, "app", "helpers.wind"),
+		filepath.Join(project, "mylib", "util.wind"),
+		filepath.Join(project, "mylib", "index.wind"),
+		filepath.Join(shared, "mylib", "util.wind"),
+		filepath.Join(shared, "json", "index.wind"),
+	}
+	for _, file := range files {
+		assert.Nil(os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(os.WriteFile(file, nil, 0644))
+	}
+
+	l := &OS{SearchPath: []string{shared}}
+	main := filepath.Join(project, "app", "main.wind")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"mylib/util", filepath.Join(project, "mylib", "util.wind")},
+		{"mylib", filepath.Join(project, "mylib", "index.wind")},
+		{"json", filepath.Join(shared, "json", "index.wind")},
+		{"./helpers", filepath.Join(project, "app", "helpers.wind")},
+		{"../mylib/util.wind", filepath.Join(project, "mylib", "util.wind")},
+		{"missing/lib", "missing/lib"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := l.Resolve(main, tt.path)
+		assert.Nil(err)
+		assert.Equal(tt.expected, resolved, tt.path)
+	}
+
+	root, ok := ProjectRoot(filepath.Join(project, "app"))
+	assert.True(ok)
+	assert.Equal(project, root)
+
+	_, ok = ProjectRoot(shared)
+	assert.False(ok)
+}
+
+func TestFS(t *testing.T) {
+	assert := assert.New(t)
+
+	l := NewFS(fstest.MapFS{
+		"scripts/lib.wind":   {Data: []byte(`let x = 1;`)},
+		"scripts/index.wind": {Data: []byte(`let y = 1;`)},
+	})
+
+	tests := []struct {
+		from     string
+		path     string
+		expected string
+	}{
+		{"scripts/main.wind", "./lib.wind", "scripts/lib.wind"},
+		{"scripts/nested/main.wind", "../lib.wind", "scripts/lib.wind"},
+		{"scripts/main.wind", "scripts/lib.wind", "scripts/lib.wind"},
+		{"scripts/main.wind", "/scripts/lib.wind", "scripts/lib.wind"},
+		{"scripts/main.wind", "./lib", "scripts/lib.wind"},
+		{"main.wind", "scripts", "scripts/index.wind"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := l.Resolve(tt.from, tt.path)
+		assert.Nil(err)
+		assert.Equal(tt.expected, resolved, tt.path)
+	}
+
+	_, err := l.Resolve("main.wind", "../lib.wind")
+	assert.EqualError(err, "resolve ../lib.wind: invalid argument")
+
+	source, err := l.Load("scripts/lib.wind")
+	assert.Nil(err)
+	assert.Equal(`let x = 1;`, source)
+
+	_, err = l.Load("scripts/missing.wind")
+	assert.NotNil(err)
+}
+
+func TestCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	stack := []Include{{"a.wind", 1}, {"b.wind", 3}, {"c.wind", 2}}
+
+	chain, ok := Cycle(stack, "b.wind")
+	assert.True(ok)
+	assert.Equal("b.wind:3 -> c.wind:2 -> b.wind", chain)
+
+	chain, ok = Cycle(stack, "a.wind")
+	assert.True(ok)
+	assert.Equal("a.wind:1 -> b.wind:3 -> c.wind:2 -> a.wind", chain)
+
+	_, ok = Cycle(stack, "d.wind")
+	assert.False(ok)
+
+	chain, ok = Cycle([]Include{{"./a.wind", 2}, {"b.wind", 1}}, "a.wind")
+	assert.True(ok)
+	assert.Equal("a.wind:2 -> b.wind:1 -> a.wind", chain)
+}
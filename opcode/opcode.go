@@ -1,5 +1,7 @@
 package opcode
 
+import "fmt"
+
 type OpCode int
 
 const (
@@ -23,4 +25,167 @@ const (
 	OP_POP
 	OP_ECHO
 	OP_ARRAY // args: [n of elements]
+	OP_TRY   // args: [offset of the handler]
+	OP_END_TRY
+	OP_THROW
+	OP_GREATER
+	OP_GREATEREQ
+	OP_NOTEQ
+	OP_AND
+	OP_OR
+	OP_NOT
+	OP_NEGATE
+	OP_DUP
+	OP_DUP2
+	OP_HASH // pushes an empty hash
+	OP_HASH_SET
+	OP_HASH_METHOD // like OP_HASH_SET, and binds `this` of the function to the hash
+	OP_INDEX
+	OP_SET_INDEX
+	OP_CLOSURE // args: [const index of the function]
+	OP_CALL    // args: [n of arguments]
+	OP_RETURN
+	OP_GET_UPVALUE // args: [upvalue index]
+	OP_SET_UPVALUE // args: [upvalue index]
+	OP_GET_BUILTIN // args: [const index of the name]
+	OP_THIS
+	OP_INCLUDE  // args: [const index of the module function, or of the name of a std library]
+	OP_IMPORT   // adds the variables of the included module on the stack to the imports of the current file
+	OP_EXPORT   // args: [const index of the name], replaces the included module on the stack with one of its variables
+	OP_ITER     // replaces the value on the stack with the values a for-in loop steps over, a range steps over itself
+	OP_NEXT     // args: [offset], pops the iterable, its steps and the index, pushes the key and the value or jumps if there are no more steps
+	OP_RANGE    // args: [range kind], pops the end unless the range is open and the start, pushes the range
+	OP_TEMPLATE // args: [n of parts], pops the parts of a template string, pushes them joined as strings
+	OP_ERROR    // args: [const index of the message], fails with the message, for the errors the compiler finds that the evaluator reports when running
+)
+
+// The kinds of range OP_RANGE makes
+const (
+	RANGE_EXCLUSIVE OpCode = iota // start..end
+	RANGE_INCLUSIVE               // start..=end
+	RANGE_OPEN                    // start..
 )
+
+// OperandCount returns the number of operands that follow the opcode in the instructions
+func (op OpCode) OperandCount() int {
+	switch op {
+	case OP_CONST, OP_LET, OP_JUMP_FALSE, OP_JUMP, OP_BLOCK, OP_ARRAY, OP_TRY,
+		OP_CLOSURE, OP_CALL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_GET_BUILTIN, OP_INCLUDE, OP_EXPORT, OP_NEXT, OP_RANGE, OP_TEMPLATE, OP_ERROR:
+		return 1
+	case OP_SET, OP_GET, OP_INC:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (op OpCode) String() string {
+	switch op {
+	case OP_CONST:
+		return "OP_CONST"
+	case OP_ADD:
+		return "OP_ADD"
+	case OP_SUBTRACT:
+		return "OP_SUBTRACT"
+	case OP_MULTIPLY:
+		return "OP_MULTIPLY"
+	case OP_DIVIDE:
+		return "OP_DIVIDE"
+	case OP_MODULO:
+		return "OP_MODULO"
+	case OP_LESS:
+		return "OP_LESS"
+	case OP_LESSEQ:
+		return "OP_LESSEQ"
+	case OP_LET:
+		return "OP_LET"
+	case OP_EQ:
+		return "OP_EQ"
+	case OP_JUMP_FALSE:
+		return "OP_JUMP_FALSE"
+	case OP_JUMP:
+		return "OP_JUMP"
+	case OP_BLOCK:
+		return "OP_BLOCK"
+	case OP_END_BLOCK:
+		return "OP_END_BLOCK"
+	case OP_SET:
+		return "OP_SET"
+	case OP_GET:
+		return "OP_GET"
+	case OP_INC:
+		return "OP_INC"
+	case OP_POP:
+		return "OP_POP"
+	case OP_ECHO:
+		return "OP_ECHO"
+	case OP_ARRAY:
+		return "OP_ARRAY"
+	case OP_TRY:
+		return "OP_TRY"
+	case OP_END_TRY:
+		return "OP_END_TRY"
+	case OP_THROW:
+		return "OP_THROW"
+	case OP_GREATER:
+		return "OP_GREATER"
+	case OP_GREATEREQ:
+		return "OP_GREATEREQ"
+	case OP_NOTEQ:
+		return "OP_NOTEQ"
+	case OP_AND:
+		return "OP_AND"
+	case OP_OR:
+		return "OP_OR"
+	case OP_NOT:
+		return "OP_NOT"
+	case OP_NEGATE:
+		return "OP_NEGATE"
+	case OP_DUP:
+		return "OP_DUP"
+	case OP_DUP2:
+		return "OP_DUP2"
+	case OP_HASH:
+		return "OP_HASH"
+	case OP_HASH_SET:
+		return "OP_HASH_SET"
+	case OP_HASH_METHOD:
+		return "OP_HASH_METHOD"
+	case OP_INDEX:
+		return "OP_INDEX"
+	case OP_SET_INDEX:
+		return "OP_SET_INDEX"
+	case OP_CLOSURE:
+		return "OP_CLOSURE"
+	case OP_CALL:
+		return "OP_CALL"
+	case OP_RETURN:
+		return "OP_RETURN"
+	case OP_GET_UPVALUE:
+		return "OP_GET_UPVALUE"
+	case OP_SET_UPVALUE:
+		return "OP_SET_UPVALUE"
+	case OP_GET_BUILTIN:
+		return "OP_GET_BUILTIN"
+	case OP_THIS:
+		return "OP_THIS"
+	case OP_INCLUDE:
+		return "OP_INCLUDE"
+	case OP_IMPORT:
+		return "OP_IMPORT"
+	case OP_EXPORT:
+		return "OP_EXPORT"
+	case OP_ITER:
+		return "OP_ITER"
+	case OP_NEXT:
+		return "OP_NEXT"
+	case OP_RANGE:
+		return "OP_RANGE"
+	case OP_TEMPLATE:
+		return "OP_TEMPLATE"
+	case OP_ERROR:
+		return "OP_ERROR"
+	default:
+		return fmt.Sprintf("OP_UNKNOWN(%d)", int(op))
+	}
+}
@@ -2,7 +2,7 @@ package parser
 
 import (
 	"fmt"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -24,9 +24,10 @@ const (
 	AND         // &&
 	EQUALS      // ==
 	LessGreater // > or <
+	RANGE       // ..
 	SUM         // +
 	PRODUCT     // *
-	PREFIX      // -X or !X
+	PREFIX      // -X, !X or ++X
 	POSTFIX     // x++ or x--
 	HIGHEST
 )
@@ -36,14 +37,22 @@ type ParserError struct {
 	Msg   string
 }
 
+// bailout is panicked by syntax errors to abandon the statement being parsed,
+// parseStatement recovers it and skips to the next statement
+type bailout struct{}
+
 type Parser struct {
 	lexer    *lexer.Lexer
 	filePath string
 
 	Errors []ParserError
 
+	prevToken token.Token // the last consumed token
 	curToken  token.Token
 	peekToken token.Token
+
+	loopDepth  int // number of loops enclosing the current statement inside the current function
+	blockDepth int // number of blocks enclosing the current statement, 0 at the top level of the file
 }
 
 func New(l *lexer.Lexer, filePath string) *Parser {
@@ -59,6 +68,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := ast.Program{
 		Statements: []ast.Statement{},
 	}
+	start := p.curToken.Span.Start
 
 	for p.curToken.Type != token.EOF {
 		statement := p.parseStatement()
@@ -68,6 +78,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 	}
 
+	program.Range = p.spanFrom(start)
+
+	// the diagnostics of the lexer are reported with the syntax errors, in the order they appear in the source
+	for _, e := range p.lexer.Errors {
+		p.Errors = append(p.Errors, ParserError{Token: e.Token, Msg: e.Msg})
+	}
+
+	sort.SliceStable(p.Errors, func(i, j int) bool {
+		return p.Errors[i].Token.Span.Start.Offset < p.Errors[j].Token.Span.Start.Offset
+	})
+
 	return &program
 }
 
@@ -75,10 +96,12 @@ func (p *Parser) getPrecedence(tokenType token.TokenType) int {
 	switch tokenType {
 	case token.EQ, token.NOT_EQ:
 		return EQUALS
-	case token.ASSIGN:
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN, token.MODULO_ASSIGN:
 		return ASSIGN
 	case token.LT, token.GT:
 		return LessGreater
+	case token.DOTDOT, token.DOTDOT_EQ:
+		return RANGE
 	case token.PLUS, token.MINUS:
 		return SUM
 	case token.SLASH, token.ASTERISK, token.MODULO:
@@ -104,7 +127,7 @@ func (p *Parser) getPrefixParseFn(tokenType token.TokenType) prefixParseFn {
 		return p.parseIntegerLiteral
 	case token.FLOAT:
 		return p.parseFloatLiteral
-	case token.BANG, token.MINUS:
+	case token.BANG, token.MINUS, token.PLUSPLUS, token.MINUSMINUS:
 		return p.parsePrefixExpression
 	case token.TRUE, token.FALSE:
 		return p.parseBoolean
@@ -116,6 +139,8 @@ func (p *Parser) getPrefixParseFn(tokenType token.TokenType) prefixParseFn {
 		return p.parseFunctionLiteral
 	case token.STRING:
 		return p.parseStringLiteral
+	case token.TEMPLATE:
+		return p.parseTemplateLiteral
 	case token.LBRACKET:
 		return p.parseArrayLiteral
 	case token.NIL:
@@ -135,26 +160,47 @@ func (p *Parser) getInfixParseFn(tokenType token.TokenType) infixParseFn {
 		return p.parseCallExpression
 	case token.PLUSPLUS, token.MINUSMINUS:
 		return p.parsePostfixExpression
-	case token.ASSIGN:
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN, token.MODULO_ASSIGN:
 		return p.parseAssignExpression
 	case token.LBRACKET:
 		return p.parseIndexExpression
 	case token.DOT:
 		return p.parseDotExpression
+	case token.DOTDOT, token.DOTDOT_EQ:
+		return p.parseRangeExpression
 	}
 
 	return nil
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
 }
 
-func (p *Parser) parseStatement() ast.Statement {
+// parseStatement parses a statement, a statement with a syntax error is dropped
+// and the parser continues from the start of the next one so each mistake is reported once
+func (p *Parser) parseStatement() (stmt ast.Statement) {
+	start, loopDepth, blockDepth := p.curToken, p.loopDepth, p.blockDepth
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+
+			p.loopDepth, p.blockDepth = loopDepth, blockDepth
+			p.synchronize(start)
+			stmt = nil
+		}
+	}()
+
 	switch p.curToken.Type {
 	case token.LET, token.CONST:
 		return p.parseVarStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
 	case token.FOR:
@@ -165,24 +211,79 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseIncludeStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
-	// case token.BREAK:
-	// 	return p.parseBreakStatement()
-	// case token.CONTINUE:
-	// 	return p.parseContinueStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	case token.ECHO:
 		return p.parseEchoStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// synchronize skips the tokens of a statement that failed to parse, it stops after a semicolon
+// or before a closing brace or a keyword that starts a statement.
+// Braces opened by the skipped tokens are skipped with their content
+func (p *Parser) synchronize(start token.Token) {
+	// the statement failed on its first token, skip it so the parser moves forward
+	if p.curToken.Span.Start == start.Span.Start {
+		p.nextToken()
+	}
+
+	for !p.currentTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			p.skipBraces()
+			continue
+		case token.SEMICOLON:
+			p.nextToken()
+			return
+		case token.RBRACE:
+			// a closing brace at the top level doesn't close anything and is skipped
+			if p.blockDepth > 0 {
+				return
+			}
+		case token.LET, token.CONST, token.EXPORT, token.RETURN, token.FOR, token.WHILE, token.INCLUDE,
+			token.BREAK, token.CONTINUE, token.ECHO, token.TRY, token.THROW:
+			return
+		}
+
+		p.nextToken()
+	}
+}
+
+// skipBraces skips from an opening brace to the brace that closes it
+func (p *Parser) skipBraces() {
+	depth := 0
+
+	for !p.currentTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+		}
+
+		p.nextToken()
+
+		if depth == 0 {
+			return
+		}
+	}
+}
+
 func (p *Parser) parseVarStatement() ast.Statement {
 	stmt := ast.LetStatement{Token: p.curToken}
 	stmt.Constant = p.curToken.Type == token.CONST
 
 	p.nextToken()
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
 
 	p.expectCurrent(token.IDENT)
 
@@ -192,9 +293,34 @@ func (p *Parser) parseVarStatement() ast.Statement {
 
 	p.expectCurrent(token.SEMICOLON)
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
+func (p *Parser) parseExportStatement() ast.Statement {
+	exportToken := p.curToken
+
+	if p.blockDepth != 0 {
+		p.Errors = append(p.Errors, ParserError{
+			Token: exportToken,
+			Msg:   "export is only allowed at the top level of a file",
+		})
+	}
+
+	p.nextToken()
+
+	if !p.currentTokenIs(token.LET) && !p.currentTokenIs(token.CONST) {
+		p.currentError(token.LET)
+	}
+
+	stmt := p.parseVarStatement().(*ast.LetStatement)
+	stmt.Exported = true
+	stmt.Range = p.spanFrom(exportToken.Span.Start)
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := ast.ReturnStatement{Token: p.curToken}
 
@@ -204,6 +330,8 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	p.expectCurrent(token.SEMICOLON)
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
@@ -214,6 +342,10 @@ func (p *Parser) parseForStatement() ast.Statement {
 
 	p.expectCurrent(token.LPAREN)
 
+	if p.currentTokenIs(token.IDENT) && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		return p.parseForInStatement(stmt.Token)
+	}
+
 	stmt.Initializer = p.parseStatement()
 
 	stmt.Condition = p.parseExpression(LOWEST)
@@ -224,7 +356,38 @@ func (p *Parser) parseForStatement() ast.Statement {
 
 	p.expectCurrent(token.RPAREN)
 
-	stmt.Body = p.parseBlockStatement()
+	stmt.Body = p.parseLoopBody()
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
+	return &stmt
+}
+
+// parseForInStatement parses the rest of a for-in loop after the opening parenthesis
+func (p *Parser) parseForInStatement(forToken token.Token) *ast.ForInStatement {
+	stmt := ast.ForInStatement{Token: forToken}
+
+	stmt.Value = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
+	p.nextToken()
+
+	if p.currentTokenIs(token.COMMA) {
+		p.nextToken()
+
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
+
+		p.expectCurrent(token.IDENT)
+	}
+
+	p.expectCurrent(token.IN)
+
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	p.expectCurrent(token.RPAREN)
+
+	stmt.Body = p.parseLoopBody()
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
 
 	return &stmt
 }
@@ -238,6 +401,8 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 		p.nextToken()
 	}
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
@@ -247,8 +412,14 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	p.expectCurrent(token.LBRACE)
 
+	p.blockDepth++
+
 	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		if stmt == nil {
+			continue
+		}
+
 		if _, ok := stmt.(*ast.LetStatement); ok {
 			block.VarCount++
 		}
@@ -256,8 +427,12 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		block.Statements = append(block.Statements, stmt)
 	}
 
+	p.blockDepth--
+
 	p.expectCurrent(token.RBRACE)
 
+	block.Range = p.spanFrom(block.Token.Span.Start)
+
 	return &block
 }
 
@@ -266,29 +441,61 @@ func (p *Parser) parseIncludeStatement() *ast.IncludeStatement {
 
 	p.nextToken()
 
-	if strings.Contains(p.curToken.Literal, "./") {
-		stmt.Path = filepath.Join(filepath.Dir(p.filePath), p.curToken.Literal)
-	} else {
-		stmt.Path = p.curToken.Literal
-	}
+	stmt.Path = p.curToken.Literal
 
 	p.nextToken()
 
 	if p.currentTokenIs(token.AS) {
 		p.nextToken()
 
-		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
 
 		p.expectCurrent(token.IDENT)
+	} else if p.currentTokenIs(token.IMPORT) {
+		p.nextToken()
 
-		p.expectCurrent(token.SEMICOLON)
-	} else {
-		p.expectCurrent(token.SEMICOLON)
+		stmt.Imports = p.parseImports()
 	}
 
+	p.expectCurrent(token.SEMICOLON)
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
+// parseImports parses the list of an include statement like { a, b as c }
+func (p *Parser) parseImports() []*ast.Import {
+	imports := []*ast.Import{}
+
+	p.expectCurrent(token.LBRACE)
+
+	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
+		imp := &ast.Import{Name: &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}}
+		imp.Alias = imp.Name
+
+		p.expectCurrent(token.IDENT)
+
+		if p.currentTokenIs(token.AS) {
+			p.nextToken()
+
+			imp.Alias = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
+
+			p.expectCurrent(token.IDENT)
+		}
+
+		imports = append(imports, imp)
+
+		if !p.currentTokenIs(token.RBRACE) {
+			p.expectCurrent(token.COMMA)
+		}
+	}
+
+	p.expectCurrent(token.RBRACE)
+
+	return imports
+}
+
 func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 	stmt := ast.WhileStatement{Token: p.curToken}
 
@@ -300,8 +507,110 @@ func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 
 	p.expectCurrent(token.RPAREN)
 
+	stmt.Body = p.parseLoopBody()
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
+	return &stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	return body
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.Errors = append(p.Errors, ParserError{
+			Token: p.curToken,
+			Msg:   "break statement outside of a loop",
+		})
+	}
+
+	p.nextToken()
+
+	p.expectCurrent(token.SEMICOLON)
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
+	return &stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.Errors = append(p.Errors, ParserError{
+			Token: p.curToken,
+			Msg:   "continue statement outside of a loop",
+		})
+	}
+
+	p.nextToken()
+
+	p.expectCurrent(token.SEMICOLON)
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
+	return &stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := ast.TryStatement{Token: p.curToken}
+
+	p.nextToken()
+
 	stmt.Body = p.parseBlockStatement()
 
+	if p.currentTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.currentTokenIs(token.LPAREN) {
+			p.nextToken()
+
+			stmt.CatchParam = &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
+
+			p.expectCurrent(token.IDENT)
+			p.expectCurrent(token.RPAREN)
+		}
+
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.currentTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.Errors = append(p.Errors, ParserError{
+			Token: p.curToken,
+			Msg:   "expected catch or finally after try block",
+		})
+	}
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
+	return &stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.expectCurrent(token.SEMICOLON)
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
@@ -314,19 +623,15 @@ func (p *Parser) parseEchoStatement() *ast.EchoStatement {
 
 	p.expectCurrent(token.SEMICOLON)
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+
 	return &stmt
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.getPrefixParseFn(p.curToken.Type)
 	if prefix == nil {
-		msg := fmt.Sprintf("cannot parse %s as an expression", p.curToken.Literal)
-		p.Errors = append(p.Errors, ParserError{
-			Token: p.curToken,
-			Msg:   msg,
-		})
-		p.nextToken()
-		return nil
+		p.syntaxError(p.curToken, fmt.Sprintf("no prefix parse function for %s", p.curToken.Type.String()))
 	}
 
 	leftExp := prefix()
@@ -356,6 +661,8 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 	expression.Right = p.parseExpression(precedence)
 
+	expression.Range = p.spanFrom(startOf(left, expression.Token))
+
 	return &expression
 }
 
@@ -364,6 +671,8 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 	p.nextToken()
 
+	ident.Range = p.spanFrom(ident.Token.Span.Start)
+
 	return &ident
 }
 
@@ -372,18 +681,15 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.Atoi(p.curToken.Literal)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.Errors = append(p.Errors, ParserError{
-			Token: p.curToken,
-			Msg:   msg,
-		})
-		return nil
+		p.syntaxError(p.curToken, fmt.Sprintf("could not parse %q as integer", p.curToken.Literal))
 	}
 
 	integer.Value = value
 
 	p.nextToken()
 
+	integer.Range = p.spanFrom(integer.Token.Span.Start)
+
 	return &integer
 }
 
@@ -392,18 +698,15 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.Errors = append(p.Errors, ParserError{
-			Token: p.curToken,
-			Msg:   msg,
-		})
-		return nil
+		p.syntaxError(p.curToken, fmt.Sprintf("could not parse %q as float", p.curToken.Literal))
 	}
 
 	float.Value = value
 
 	p.nextToken()
 
+	float.Range = p.spanFrom(float.Token.Span.Start)
+
 	return &float
 }
 
@@ -412,9 +715,48 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 
 	p.nextToken()
 
+	str.Range = p.spanFrom(str.Token.Span.Start)
+
 	return &str
 }
 
+// parseTemplateLiteral parses a string with interpolations, the lexer returns the parts of the string
+// around the tokens of the interpolations as a TEMPLATE, TEMPLATE_MIDDLE tokens and a TEMPLATE_END
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	exp := ast.TemplateLiteral{Token: p.curToken}
+
+	for p.currentTokenIs(token.TEMPLATE) || p.currentTokenIs(token.TEMPLATE_MIDDLE) {
+		exp.Parts = p.appendTemplateString(exp.Parts)
+
+		if p.currentTokenIs(token.TEMPLATE_MIDDLE) || p.currentTokenIs(token.TEMPLATE_END) {
+			p.syntaxError(p.curToken, "empty interpolation in string")
+		}
+
+		exp.Parts = append(exp.Parts, p.parseExpression(LOWEST))
+
+		if !p.currentTokenIs(token.TEMPLATE_MIDDLE) && !p.currentTokenIs(token.TEMPLATE_END) {
+			p.syntaxError(p.curToken, fmt.Sprintf("expected } to close the interpolation, got %s instead", p.curToken.Type.String()))
+		}
+	}
+
+	exp.Parts = p.appendTemplateString(exp.Parts)
+
+	exp.Range = p.spanFrom(exp.Token.Span.Start)
+
+	return &exp
+}
+
+// appendTemplateString appends the string of the current part of a template unless it's empty
+func (p *Parser) appendTemplateString(parts []ast.Expression) []ast.Expression {
+	if p.curToken.Literal != "" {
+		parts = append(parts, &ast.StringLiteral{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal})
+	}
+
+	p.nextToken()
+
+	return parts
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := ast.PrefixExpression{
 		Token:    p.curToken,
@@ -425,6 +767,8 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 	expression.Right = p.parseExpression(PREFIX)
 
+	expression.Range = p.spanFrom(expression.Token.Span.Start)
+
 	return &expression
 }
 
@@ -433,6 +777,8 @@ func (p *Parser) parseBoolean() ast.Expression {
 
 	p.nextToken()
 
+	expr.Range = p.spanFrom(expr.Token.Span.Start)
+
 	return &expr
 }
 
@@ -467,6 +813,8 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		expression.ElseBranch = elseStatement
 	}
 
+	expression.Range = p.spanFrom(expression.Token.Span.Start)
+
 	return &expression
 
 }
@@ -480,7 +828,13 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 
 	lit.Parameters = p.parseFunctionParameters()
 
+	// loops outside of the function can't be controlled from its body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	lit.Range = p.spanFrom(lit.Token.Span.Start)
 
 	return &lit
 }
@@ -493,19 +847,19 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	for p.peekTokenIs(token.COMMA) {
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	for {
+		ident := &ast.Identifier{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
 
-		p.nextToken() // consume IDENT
+		p.expectCurrent(token.IDENT)
+
+		if !p.currentTokenIs(token.COMMA) {
+			break
+		}
+
 		p.nextToken() // consume COMMA
 	}
 
-	// last IDENT
-	ident := ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, &ident)
-	p.nextToken()
-
 	p.expectCurrent(token.RPAREN)
 
 	return identifiers
@@ -518,6 +872,8 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 
 	exp.Arguments = p.parseCallArguments(token.RPAREN)
 
+	exp.Range = p.spanFrom(startOf(function, exp.Token))
+
 	return &exp
 }
 
@@ -528,6 +884,8 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 	exp.Value = p.parseCallArguments(token.RBRACKET)
 
+	exp.Range = p.spanFrom(exp.Token.Span.Start)
+
 	return &exp
 }
 
@@ -536,6 +894,8 @@ func (p *Parser) parseNilLiteral() ast.Expression {
 
 	p.nextToken()
 
+	exp.Range = p.spanFrom(exp.Token.Span.Start)
+
 	return &exp
 }
 
@@ -563,6 +923,8 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 	p.expectCurrent(token.RBRACE)
 
+	hash.Range = p.spanFrom(hash.Token.Span.Start)
+
 	return &hash
 }
 
@@ -595,6 +957,8 @@ func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
 
 	p.nextToken()
 
+	expression.Range = p.spanFrom(startOf(left, expression.Token))
+
 	return &expression
 }
 
@@ -604,15 +968,54 @@ func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 		Name:  left,
 	}
 
+	// a compound assignment like += applies its operator to the old value and the assigned value
+	if !p.currentTokenIs(token.ASSIGN) {
+		expression.Operator = strings.TrimSuffix(p.curToken.Literal, "=")
+	}
+
 	precedence := p.curPrecedence()
 
-	p.nextToken() // consume ASSIGN
+	p.nextToken() // consume the assignment operator
 
 	expression.Value = p.parseExpression(precedence)
 
+	expression.Range = p.spanFrom(startOf(left, expression.Token))
+
+	return &expression
+}
+
+// parseRangeExpression parses start..end and start..=end, the end of a start.. range is left out
+// when the range is followed by a token that ends the expression like in arr[2..]
+func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
+	expression := ast.RangeExpression{
+		Token:     p.curToken,
+		Start:     left,
+		Inclusive: p.currentTokenIs(token.DOTDOT_EQ),
+	}
+
+	precedence := p.curPrecedence()
+
+	p.nextToken()
+
+	if expression.Inclusive || !p.endsExpression() {
+		expression.End = p.parseExpression(precedence)
+	}
+
+	expression.Range = p.spanFrom(startOf(left, expression.Token))
+
 	return &expression
 }
 
+// endsExpression reports whether the current token closes the expression being parsed
+func (p *Parser) endsExpression() bool {
+	switch p.curToken.Type {
+	case token.RBRACKET, token.RPAREN, token.RBRACE, token.COMMA, token.SEMICOLON, token.EOF:
+		return true
+	}
+
+	return false
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -622,6 +1025,8 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	p.expectCurrent(token.RBRACKET)
 
+	exp.Range = p.spanFrom(startOf(left, exp.Token))
+
 	return &exp
 }
 
@@ -631,13 +1036,29 @@ func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
 	p.expectPeek(token.IDENT)
 
 	p.curToken.Type = token.STRING
-	exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	exp.Index = &ast.StringLiteral{Token: p.curToken, Range: p.curToken.Span, Value: p.curToken.Literal}
 
 	p.nextToken()
 
+	exp.Range = p.spanFrom(startOf(left, exp.Token))
+
 	return &exp
 }
 
+// spanFrom returns the span from start to the end of the last consumed token
+func (p *Parser) spanFrom(start token.Position) token.Span {
+	return token.Span{Start: start, End: p.prevToken.Span.End}
+}
+
+// startOf returns where the node starts, falling back to the token if the node failed to parse
+func startOf(node ast.Node, fallback token.Token) token.Position {
+	if node == nil {
+		return fallback.Span.Start
+	}
+
+	return node.Span().Start
+}
+
 func (p *Parser) curPrecedence() int {
 	return p.getPrecedence(p.curToken.Type)
 }
@@ -674,28 +1095,40 @@ func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t.String(), p.peekToken.Type.String())
 
-	p.Errors = append(p.Errors, ParserError{
-		Token: p.peekToken,
-		Msg:   msg,
-	})
+	p.syntaxError(p.peekToken, msg)
 }
 
 func (p *Parser) currentError(t token.TokenType) {
 	msg := fmt.Sprintf("expected token to be %s, got %s instead",
 		t.String(), p.curToken.Type.String())
 
-	p.Errors = append(p.Errors, ParserError{
-		Token: p.curToken,
-		Msg:   msg,
-	})
+	p.syntaxError(p.curToken, msg)
+}
+
+// syntaxError records the error and abandons the current statement,
+// an ILLEGAL token is already reported by the lexer so only the statement is abandoned
+func (p *Parser) syntaxError(tok token.Token, msg string) {
+	if tok.Type != token.ILLEGAL {
+		p.Errors = append(p.Errors, ParserError{
+			Token: tok,
+			Msg:   msg,
+		})
+	}
+
+	panic(bailout{})
 }
 
 func (p *Parser) ReportErrors() []string {
 	errors := []string{}
+	source := p.lexer.Source()
 
 	if len(p.Errors) != 0 {
 		for _, e := range p.Errors {
-			errors = append(errors, fmt.Sprintf("[file %s:%d]: %s", p.filePath, e.Token.Line, e.Msg))
+			errors = append(errors, fmt.Sprintf(
+				"[file %s:%d:%d]: %s\n%s",
+				p.filePath, e.Token.Span.Start.Line, e.Token.Span.Start.Column, e.Msg,
+				e.Token.Span.Highlight(source),
+			))
 		}
 	}
 
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/joetifa2003/windlang/ast"
+	"github.com/joetifa2003/windlang/lexer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseErrors(input string) []string {
+	p := New(lexer.New(input), "test.wind")
+	p.ParseProgram()
+
+	errors := []string{}
+	for _, e := range p.Errors {
+		errors = append(errors, e.Msg)
+	}
+
+	return errors
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; let y = x + 2;", []string{}},
+		{"let x = ;", []string{"no prefix parse function for ;"}},
+		{"let = 1; let y = 2 +; let z = 3;", []string{
+			"expected token to be IDENT, got = instead",
+			"no prefix parse function for ;",
+		}},
+		{"let f = fn(a, 1) { return a; }; println(f(1));", []string{"expected token to be IDENT, got INT instead"}},
+		{"while (true { break; } let ok = 1;", []string{"expected token to be ), got { instead"}},
+		{"let h = {\"a\": 1, \"b\" 2};", []string{"expected token to be :, got INT instead"}},
+		{"let h = {\"a\": 1", []string{"expected token to be ,, got EOF instead"}},
+		{"fn() { let x = ; let y = ); return 1; }", []string{
+			"no prefix parse function for ;",
+			"no prefix parse function for )",
+		}},
+		{"} let x = 1;", []string{"no prefix parse function for }"}},
+		{"include \"a.wind\" import { a, 1 };", []string{"expected token to be IDENT, got INT instead"}},
+		{"let a = 1 & 2; let b = ; let c = 1.2.3;", []string{
+			"unknown character U+0026 '&'",
+			"no prefix parse function for ;",
+			"malformed number 1.2.3",
+		}},
+		{"let s = \"abc;", []string{"unterminated string starting at line 1"}},
+		{"let s = \"a\\qb\"; let t = ;", []string{
+			"invalid escape sequence \\q",
+			"no prefix parse function for ;",
+		}},
+		{"let a = \"${}\"; let b = \"${1 2}\"; let c = \"${x\";", []string{
+			"empty interpolation in string",
+			"expected } to close the interpolation, got INT instead",
+			"unterminated string starting at line 1",
+		}},
+		{"for (k, v in 0..3) { } for (k, in x) { } for (x y) { }", []string{
+			"expected token to be IDENT, got IN instead",
+			"expected token to be ;, got ) instead",
+		}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(tc.expected, parseErrors(tc.input), tc.input)
+	}
+}
+
+func TestRecoveredProgram(t *testing.T) {
+	assert := assert.New(t)
+
+	p := New(lexer.New("let x = ; let y = 2; { let z = ); echo y; }"), "test.wind")
+	program := p.ParseProgram()
+
+	assert.Len(p.Errors, 2)
+	if assert.Len(program.Statements, 2) {
+		assert.Equal("let y = 2;", program.Statements[0].String())
+
+		block, ok := program.Statements[1].(*ast.BlockStatement)
+		if assert.True(ok) {
+			assert.Len(block.Statements, 1)
+			assert.Equal(0, block.VarCount)
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0..10", "(0..10)"},
+		{"0..=10", "(0..=10)"},
+		{"1 + 2..n * 2", "((1 + 2)..(n * 2))"},
+		{"a[1..]", "(a[(1..)])"},
+		{"(0..).step(2)", "((0..)[step])(2)"},
+		{"i < 0..3", "(i < (0..3))"},
+	}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input), "test.wind")
+		program := p.ParseProgram()
+
+		if assert.Empty(p.Errors, tc.input) && assert.Len(program.Statements, 1, tc.input) {
+			assert.Equal(tc.expected, program.Statements[0].String(), tc.input)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "(x = 1)"},
+		{"x += 1 + 2", "(x += (1 + 2))"},
+		{"a[0] -= 1", "((a[0]) -= 1)"},
+		{"h.count *= 2", "((h[count]) *= 2)"},
+		{"x /= y %= 3", "(x /= (y %= 3))"},
+		{"++x", "(++x)"},
+		{"--a[0]", "(--(a[0]))"},
+		{"x--", "(x --)"},
+	}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input), "test.wind")
+		program := p.ParseProgram()
+
+		if assert.Empty(p.Errors, tc.input) && assert.Len(program.Statements, 1, tc.input) {
+			assert.Equal(tc.expected, program.Statements[0].String(), tc.input)
+		}
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected string
+		parts    int
+	}{
+		{`"a ${x + 1} b"`, "a ${(x + 1)} b", 3},
+		{`"${x}${y}"`, "${x}${y}", 2},
+		{`"${"${x}"}!"`, "${${x}}!", 2},
+		{"`${x}`", "${x}", 1},
+	}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input), "test.wind")
+		program := p.ParseProgram()
+
+		if assert.Empty(p.Errors, tc.input) && assert.Len(program.Statements, 1, tc.input) {
+			assert.Equal(tc.expected, program.Statements[0].String(), tc.input)
+
+			if template, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.TemplateLiteral); ok {
+				assert.Len(template.Parts, tc.parts, tc.input)
+			}
+		}
+	}
+}
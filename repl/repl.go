@@ -0,0 +1,283 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joetifa2003/windlang/evaluator"
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/token"
+)
+
+const (
+	fileName       = "repl"
+	prompt         = ">> "
+	continuePrompt = ".. "
+)
+
+const help = `Enter Wind code to evaluate it, unbalanced brackets continue the input on the next line.
+
+Commands:
+  :load <file>  evaluate a file in the current environment
+  :env          list the variables of the current environment
+  :history      show the inputs of this and previous sessions
+  :reset        start over with an empty environment
+  :help         show this message
+  :quit         exit the repl
+`
+
+type Repl struct {
+	in          *bufio.Reader // the lines of the repl and what the input builtin reads
+	out         io.Writer
+	historyPath string
+	history     []string
+
+	envManager *evaluator.EnvironmentManager
+	env        *evaluator.Environment
+}
+
+// New creates a repl reading from in and writing to out,
+// historyPath is the file used to persist the inputs between sessions, empty to disable it
+func New(in io.Reader, out io.Writer, historyPath string) *Repl {
+	r := Repl{
+		in:          bufio.NewReader(in),
+		out:         out,
+		historyPath: historyPath,
+	}
+
+	r.loadHistory()
+	r.reset()
+
+	return &r
+}
+
+func (r *Repl) Start() {
+	for {
+		input, ok := r.read()
+		if !ok {
+			return
+		}
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		r.addHistory(input)
+
+		if strings.HasPrefix(input, ":") {
+			if quit := r.runCommand(input); quit {
+				return
+			}
+
+			continue
+		}
+
+		r.eval(input, fileName)
+	}
+}
+
+// read returns the next input, reading more lines while its brackets are unbalanced
+func (r *Repl) read() (string, bool) {
+	var lines []string
+
+	fmt.Fprint(r.out, prompt)
+	for {
+		line, ok := r.readLine()
+		if !ok {
+			break
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+
+		if nesting(input) <= 0 {
+			return input, true
+		}
+
+		fmt.Fprint(r.out, continuePrompt)
+	}
+
+	if len(lines) != 0 {
+		return strings.Join(lines, "\n"), true
+	}
+
+	fmt.Fprintln(r.out)
+	return "", false
+}
+
+// readLine returns the next line without its line ending, ok is false at the end of the input
+func (r *Repl) readLine() (string, bool) {
+	line, err := r.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// nesting returns how many brackets of the input are still open
+func nesting(input string) int {
+	depth := 0
+
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+
+	return depth
+}
+
+func (r *Repl) eval(input string, filePath string) {
+	l := lexer.New(input)
+	p := parser.New(l, filePath)
+	program := p.ParseProgram()
+
+	parserErrors := p.ReportErrors()
+	if len(parserErrors) > 0 {
+		for _, err := range parserErrors {
+			fmt.Fprintln(r.out, err)
+		}
+
+		return
+	}
+
+	r.envManager.SetSource(filePath, input)
+	ev := evaluator.New(r.envManager, filePath)
+	ev.SetStdout(r.out)
+	ev.SetStdin(r.in)
+	evaluated, err := ev.Eval(program, r.env, nil)
+	if err != nil {
+		fmt.Fprintln(r.out, err.Inspect())
+		fmt.Fprintln(r.out, err.StackTrace())
+		return
+	}
+
+	if evaluated != nil && evaluated != evaluator.NIL {
+		fmt.Fprintln(r.out, evaluated.Inspect())
+	}
+}
+
+// runCommand executes a meta command and reports whether the repl should exit
+func (r *Repl) runCommand(input string) bool {
+	fields := strings.Fields(input)
+	command, args := fields[0], fields[1:]
+
+	switch command {
+	case ":load":
+		if len(args) != 1 {
+			fmt.Fprintln(r.out, "usage: :load <file>")
+			return false
+		}
+
+		file, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintln(r.out, "could not read file:", err)
+			return false
+		}
+
+		r.eval(string(file), args[0])
+
+	case ":env":
+		r.printEnv()
+
+	case ":history":
+		for i, entry := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, escapeLines.Replace(entry))
+		}
+
+	case ":reset":
+		r.reset()
+
+	case ":help":
+		fmt.Fprint(r.out, help)
+
+	case ":quit", ":exit":
+		return true
+
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, type :help for a list of commands\n", command)
+	}
+
+	return false
+}
+
+func (r *Repl) printEnv() {
+	bindings := map[string]string{}
+	for name, value := range r.env.Store {
+		bindings[name] = "let " + name + " = " + value.Inspect()
+	}
+	for name, value := range r.env.ConstantStore {
+		bindings[name] = "const " + name + " = " + value.Inspect()
+	}
+
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintln(r.out, bindings[name])
+	}
+}
+
+func (r *Repl) reset() {
+	r.envManager = evaluator.NewEnvironmentManager()
+	r.env, _ = r.envManager.Get(fileName)
+}
+
+// escapeLines shows a multi-line entry of the history on one line, backslashes are escaped
+// so the line breaks can be told apart from the ones written in string literals
+var escapeLines = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func (r *Repl) loadHistory() {
+	if r.historyPath == "" {
+		return
+	}
+
+	file, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(file), "\n") {
+		if line == "" {
+			continue
+		}
+
+		entry, err := strconv.Unquote(line)
+		if err != nil {
+			entry = line
+		}
+
+		r.history = append(r.history, entry)
+	}
+}
+
+func (r *Repl) addHistory(input string) {
+	r.history = append(r.history, input)
+
+	if r.historyPath == "" {
+		return
+	}
+
+	file, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	// entries are quoted so multi-line inputs take a single line and come back as they were typed
+	fmt.Fprintln(file, strconv.Quote(input))
+}
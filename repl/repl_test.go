@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNesting(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let x = 1;", 0},
+		{"let f = fn(x) {", 1},
+		{"let f = fn(x) { [1, 2", 2},
+		{"}", -1},
+		{`"{"`, 0},
+		{`"${ {"a": 1}["a"] }"`, 0},
+		{`"${ fn() {`, 1},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(tc.expected, nesting(tc.input))
+	}
+}
+
+func TestReplPersistsEnvironment(t *testing.T) {
+	assert := assert.New(t)
+
+	input := strings.Join([]string{
+		"let x = 40;",
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"add(x, 2)",
+		":env",
+		":reset",
+		":env",
+		":quit",
+		"x",
+	}, "\n")
+
+	var out bytes.Buffer
+	New(strings.NewReader(input), &out, "").Start()
+
+	assert.Contains(out.String(), "42\n")
+	assert.Contains(out.String(), "let x = 40\n")
+	assert.Equal(1, strings.Count(out.String(), "let x = 40"))
+	assert.NotContains(out.String(), "identifier not found")
+}
+
+func TestHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	historyPath := filepath.Join(t.TempDir(), "history")
+	input := strings.Join([]string{
+		"let f = fn() {",
+		`  "a\nb"`,
+		"};",
+		":quit",
+	}, "\n")
+
+	New(strings.NewReader(input), &bytes.Buffer{}, historyPath).Start()
+
+	var out bytes.Buffer
+	r := New(strings.NewReader(":history"), &out, historyPath)
+	assert.Equal([]string{"let f = fn() {\n  \"a\\nb\"\n};", ":quit"}, r.history)
+
+	r.Start()
+	assert.Contains(out.String(), "   1  let f = fn() {\\n  \"a\\\\nb\"\\n};\n   2  :quit\n")
+}
+
+func TestReplInput(t *testing.T) {
+	assert := assert.New(t)
+
+	// the answer is the line after the statement, the repl doesn't read it as code
+	input := strings.Join([]string{
+		`let name = input("name? ");`,
+		"wind",
+		`"hello " + name`,
+	}, "\n")
+
+	var out bytes.Buffer
+	New(strings.NewReader(input), &out, "").Start()
+
+	assert.Contains(out.String(), "name? ")
+	assert.Contains(out.String(), "hello wind\n")
+	assert.NotContains(out.String(), "identifier not found")
+}
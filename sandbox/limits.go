@@ -0,0 +1,105 @@
+// Package sandbox holds what the evaluator and the vm need to run untrusted scripts
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrLimitExceeded is wrapped by the errors of scripts that went over one of their limits
+	ErrLimitExceeded = errors.New("limit exceeded")
+
+	// ErrCancelled is wrapped by the errors of scripts stopped because their context is done
+	ErrCancelled = errors.New("cancelled")
+)
+
+// Limits bounds the work a script can do, zero means no limit
+type Limits struct {
+	MaxSteps      int // the number of nodes the evaluator evaluates or instructions the vm executes
+	MaxCallDepth  int // the number of nested function calls
+	MaxArraySize  int // the number of elements of an array
+	MaxStringSize int // the number of bytes of a string
+}
+
+// IsFatal reports whether the error stopped the script because of its limits or its context,
+// these errors can't be caught by the script
+func IsFatal(err error) bool {
+	return errors.Is(err, ErrLimitExceeded) || errors.Is(err, ErrCancelled)
+}
+
+// checkInterval is the number of steps between two checks of the context
+const checkInterval = 1024
+
+// Counter counts the steps of a script and checks them against its limits and its context
+type Counter struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+	next   int // the step of the next check
+}
+
+func NewCounter(ctx context.Context, limits Limits) *Counter {
+	return &Counter{ctx: ctx, limits: limits}
+}
+
+// Step counts a step, the context is only checked every once in a while so it's cheap to call
+func (c *Counter) Step() error {
+	c.steps++
+	if c.steps < c.next {
+		return nil
+	}
+
+	return c.check()
+}
+
+func (c *Counter) check() error {
+	if c.limits.MaxSteps != 0 && c.steps > c.limits.MaxSteps {
+		return fmt.Errorf("%w: more than %d steps", ErrLimitExceeded, c.limits.MaxSteps)
+	}
+
+	if c.ctx.Done() == nil {
+		c.next = math.MaxInt
+	} else {
+		if err := c.ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrCancelled, err)
+		}
+
+		c.next = c.steps + checkInterval
+	}
+
+	if c.limits.MaxSteps != 0 && c.next > c.limits.MaxSteps+1 {
+		c.next = c.limits.MaxSteps + 1
+	}
+
+	return nil
+}
+
+// CallDepth checks the number of nested calls
+func (c *Counter) CallDepth(depth int) error {
+	if c.limits.MaxCallDepth != 0 && depth > c.limits.MaxCallDepth {
+		return fmt.Errorf("%w: more than %d nested calls", ErrLimitExceeded, c.limits.MaxCallDepth)
+	}
+
+	return nil
+}
+
+// ArraySize checks the number of elements of an array
+func (c *Counter) ArraySize(size int) error {
+	if c.limits.MaxArraySize != 0 && size > c.limits.MaxArraySize {
+		return fmt.Errorf("%w: array of more than %d elements", ErrLimitExceeded, c.limits.MaxArraySize)
+	}
+
+	return nil
+}
+
+// StringSize checks the number of bytes of a string
+func (c *Counter) StringSize(size int) error {
+	if c.limits.MaxStringSize != 0 && size > c.limits.MaxStringSize {
+		return fmt.Errorf("%w: string of more than %d bytes", ErrLimitExceeded, c.limits.MaxStringSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCounter(context.Background(), Limits{MaxSteps: 3, MaxCallDepth: 2, MaxArraySize: 1, MaxStringSize: 4})
+	for i := 0; i < 3; i++ {
+		assert.Nil(c.Step())
+	}
+	assert.ErrorIs(c.Step(), ErrLimitExceeded)
+	assert.ErrorIs(c.Step(), ErrLimitExceeded)
+
+	assert.Nil(c.CallDepth(2))
+	assert.EqualError(c.CallDepth(3), "limit exceeded: more than 2 nested calls")
+	assert.Nil(c.ArraySize(1))
+	assert.EqualError(c.ArraySize(2), "limit exceeded: array of more than 1 elements")
+	assert.Nil(c.StringSize(4))
+	assert.EqualError(c.StringSize(5), "limit exceeded: string of more than 4 bytes")
+}
+
+func TestCounterContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewCounter(ctx, Limits{})
+
+	assert.Nil(t, c.Step())
+	cancel()
+
+	var err error
+	for i := 0; i <= checkInterval && err == nil; i++ {
+		err = c.Step()
+	}
+
+	assert.EqualError(t, err, "cancelled: context canceled")
+	assert.True(t, IsFatal(fmt.Errorf("wrapped: %w", err)))
+	assert.False(t, IsFatal(errors.New("other")))
+}
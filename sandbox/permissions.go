@@ -0,0 +1,105 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPermissionDenied is wrapped by the errors of scripts that access what their permissions don't allow
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Permissions is what a script can access, a nil *Permissions allows everything
+type Permissions struct {
+	Modules      []string // the std library modules that can be included, nil allows all of them
+	FSRoots      []string // the directories whose files can be included and accessed with the fs module
+	IncludeRoots []string // the directories whose files can be included but not accessed with the fs module
+	AllowNet     bool     // whether network requests can be made
+}
+
+// CheckModule returns an error if the std library module can't be included
+func (p *Permissions) CheckModule(name string) error {
+	if p == nil || p.Modules == nil {
+		return nil
+	}
+
+	for _, module := range p.Modules {
+		if module == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: module %s is not allowed", ErrPermissionDenied, name)
+}
+
+// CheckInclude returns an error if the file can't be included
+func (p *Permissions) CheckInclude(path string) error {
+	if p == nil || inside(path, p.FSRoots) || inside(path, p.IncludeRoots) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: cannot include %s, it's outside the allowed directories", ErrPermissionDenied, path)
+}
+
+// CheckFile returns an error if the file can't be read or written
+func (p *Permissions) CheckFile(path string) error {
+	if p == nil || inside(path, p.FSRoots) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: cannot access %s, it's outside the allowed directories", ErrPermissionDenied, path)
+}
+
+// CheckNet returns an error if network requests can't be made
+func (p *Permissions) CheckNet(url string) error {
+	if p == nil || p.AllowNet {
+		return nil
+	}
+
+	return fmt.Errorf("%w: network access to %s is not allowed", ErrPermissionDenied, url)
+}
+
+// inside reports whether the path is in one of the directories, symbolic links
+// are resolved first so they can't be used to leave the directories
+func inside(path string, roots []string) bool {
+	path = resolve(path)
+
+	for _, root := range roots {
+		root = resolve(root)
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolve returns the absolute path without symbolic links,
+// the parts of the path that don't exist yet are kept as they are
+func resolve(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	dir, rest := abs, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs
+		}
+
+		dir, rest = parent, filepath.Join(filepath.Base(dir), rest)
+	}
+}
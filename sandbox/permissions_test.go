@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions(t *testing.T) {
+	assert := assert.New(t)
+
+	var all *Permissions
+	assert.Nil(all.CheckModule("request"))
+	assert.Nil(all.CheckInclude("/etc/passwd"))
+	assert.Nil(all.CheckFile("/etc/passwd"))
+	assert.Nil(all.CheckNet("http://example.com"))
+
+	p := &Permissions{Modules: []string{"math"}}
+	assert.Nil(p.CheckModule("math"))
+	assert.EqualError(p.CheckModule("request"), "permission denied: module request is not allowed")
+	assert.ErrorIs(p.CheckNet("http://example.com"), ErrPermissionDenied)
+	assert.EqualError(p.CheckNet("http://example.com"), "permission denied: network access to http://example.com is not allowed")
+
+	p = &Permissions{AllowNet: true}
+	assert.Nil(p.CheckModule("request"))
+	assert.Nil(p.CheckNet("http://example.com"))
+}
+
+func TestPermissionsRoots(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	lib := filepath.Join(dir, "lib")
+	assert.Nil(os.Mkdir(data, 0755))
+	assert.Nil(os.Mkdir(lib, 0755))
+	assert.Nil(os.Symlink(dir, filepath.Join(data, "up")))
+
+	p := &Permissions{FSRoots: []string{data}, IncludeRoots: []string{lib}}
+
+	assert.Nil(p.CheckFile(filepath.Join(data, "a.txt")))
+	assert.Nil(p.CheckFile(filepath.Join(data, "new", "b.txt")))
+	assert.Nil(p.CheckInclude(filepath.Join(data, "a.wind")))
+	assert.Nil(p.CheckInclude(filepath.Join(lib, "a.wind")))
+
+	assert.EqualError(
+		p.CheckFile(filepath.Join(lib, "a.wind")),
+		"permission denied: cannot access "+filepath.Join(lib, "a.wind")+", it's outside the allowed directories",
+	)
+	assert.ErrorIs(p.CheckFile(filepath.Join(data, "..", "secret")), ErrPermissionDenied)
+	assert.ErrorIs(p.CheckFile(filepath.Join(data, "up", "secret")), ErrPermissionDenied)
+	assert.ErrorIs(p.CheckFile(data+"2/a.txt"), ErrPermissionDenied)
+	assert.EqualError(
+		p.CheckInclude(filepath.Join(dir, "a.wind")),
+		"permission denied: cannot include "+filepath.Join(dir, "a.wind")+", it's outside the allowed directories",
+	)
+}
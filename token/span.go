@@ -0,0 +1,68 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a location in the source, Offset is counted in runes from the start of the input
+// and Line and Column start at 1
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the source range covered by a token or a node, End points right after the last rune
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Highlight renders the first source line of the span with a caret underline below the span
+func (s Span) Highlight(source string) string {
+	lines := strings.Split(source, "\n")
+	if s.Start.Line < 1 || s.Start.Line > len(lines) {
+		return ""
+	}
+
+	line := strings.TrimRight(lines[s.Start.Line-1], "\r")
+	runes := []rune(line)
+
+	start := s.Start.Column - 1
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+
+	end := len(runes)
+	if s.End.Line == s.Start.Line && s.End.Column-1 < end {
+		end = s.End.Column - 1
+	}
+
+	width := end - start
+	if width < 1 {
+		width = 1
+	}
+
+	// keep tabs so the caret lines up with the source line
+	var padding strings.Builder
+	for _, ch := range runes[:start] {
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	lineNumber := fmt.Sprint(s.Start.Line)
+	gutter := strings.Repeat(" ", len(lineNumber))
+
+	return fmt.Sprintf(
+		"%s | %s\n%s | %s%s",
+		lineNumber, line,
+		gutter, padding.String(), strings.Repeat("^", width),
+	)
+}
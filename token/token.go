@@ -4,6 +4,7 @@ type Token struct {
 	Type    TokenType
 	Literal string
 	Line    int
+	Span    Span
 }
 
 func LookupIdent(ident string) TokenType {
@@ -48,6 +49,20 @@ func isKeyword(ident string) (TokenType, bool) {
 		return CONTINUE, true
 	case "echo":
 		return ECHO, true
+	case "try":
+		return TRY, true
+	case "catch":
+		return CATCH, true
+	case "finally":
+		return FINALLY, true
+	case "throw":
+		return THROW, true
+	case "export":
+		return EXPORT, true
+	case "import":
+		return IMPORT, true
+	case "in":
+		return IN, true
 	}
 
 	return IDENT, false
@@ -11,6 +11,9 @@ const (
 	INT   // 1343456
 	STRING
 	FLOAT
+	TEMPLATE        // the part of a string before its first interpolation, "...${
+	TEMPLATE_MIDDLE // the part of a string between two interpolations, }...${
+	TEMPLATE_END    // the part of a string after its last interpolation, }..."
 
 	// Operators
 	ASSIGN
@@ -30,7 +33,15 @@ const (
 	NOT_EQ // !=
 	PLUSPLUS
 	MINUSMINUS
-	DOTDOT
+	DOTDOT    // ..
+	DOTDOT_EQ // ..=
+
+	// Compound assignments
+	PLUS_ASSIGN     // +=
+	MINUS_ASSIGN    // -=
+	ASTERISK_ASSIGN // *=
+	SLASH_ASSIGN    // /=
+	MODULO_ASSIGN   // %=
 
 	// Delimiters
 	COMMA
@@ -61,6 +72,13 @@ const (
 	BREAK
 	CONTINUE
 	ECHO
+	TRY
+	CATCH
+	FINALLY
+	THROW
+	EXPORT
+	IMPORT
+	IN
 )
 
 func (t *TokenType) String() string {
@@ -93,8 +111,22 @@ func (t *TokenType) String() string {
 		return "=="
 	case NOT_EQ:
 		return "!="
+	case MODULO:
+		return "%"
+	case LT_EQ:
+		return "<="
+	case GT_EQ:
+		return ">="
+	case AND:
+		return "&&"
+	case OR:
+		return "||"
 	case COMMA:
 		return ","
+	case COLON:
+		return ":"
+	case DOT:
+		return "."
 	case SEMICOLON:
 		return ";"
 	case LPAREN:
@@ -105,10 +137,16 @@ func (t *TokenType) String() string {
 		return "{"
 	case RBRACE:
 		return "}"
+	case LBRACKET:
+		return "["
+	case RBRACKET:
+		return "]"
 	case FUNCTION:
 		return "FUNCTION"
 	case LET:
 		return "LET"
+	case CONST:
+		return "CONST"
 	case TRUE:
 		return "TRUE"
 	case FALSE:
@@ -121,12 +159,28 @@ func (t *TokenType) String() string {
 		return "RETURN"
 	case STRING:
 		return "STRING"
+	case TEMPLATE:
+		return "TEMPLATE"
+	case TEMPLATE_MIDDLE:
+		return "TEMPLATE_MIDDLE"
+	case TEMPLATE_END:
+		return "TEMPLATE_END"
 	case FOR:
 		return "FOR"
 	case PLUSPLUS:
 		return "++"
 	case MINUSMINUS:
 		return "--"
+	case PLUS_ASSIGN:
+		return "+="
+	case MINUS_ASSIGN:
+		return "-="
+	case ASTERISK_ASSIGN:
+		return "*="
+	case SLASH_ASSIGN:
+		return "/="
+	case MODULO_ASSIGN:
+		return "%="
 	case INCLUDE:
 		return "INCLUDE"
 	case WHILE:
@@ -138,13 +192,29 @@ func (t *TokenType) String() string {
 	case AS:
 		return "AS"
 	case DOTDOT:
-		return "DOTDOT"
+		return ".."
+	case DOTDOT_EQ:
+		return "..="
 	case BREAK:
 		return "BREAK"
 	case CONTINUE:
 		return "CONTINUE"
 	case ECHO:
 		return "ECHO"
+	case TRY:
+		return "TRY"
+	case CATCH:
+		return "CATCH"
+	case FINALLY:
+		return "FINALLY"
+	case THROW:
+		return "THROW"
+	case EXPORT:
+		return "EXPORT"
+	case IMPORT:
+		return "IMPORT"
+	case IN:
+		return "IN"
 	default:
 		return "UNKNOWN"
 	}
@@ -0,0 +1,119 @@
+package value
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Range is the lazy sequence of the integers from Start to End by Step, End is excluded unless Inclusive.
+// An open range has no end, it slices arrays and strings up to their end and doesn't stop when iterated
+type Range struct {
+	Start     int
+	End       int
+	Step      int
+	Inclusive bool
+	Open      bool
+}
+
+// Len returns the number of integers of a range that isn't open
+func (r *Range) Len() int {
+	end := r.End
+	if r.Inclusive {
+		if r.Step > 0 {
+			end++
+		} else {
+			end--
+		}
+	}
+
+	var n int
+	if r.Step > 0 {
+		n = (end - r.Start + r.Step - 1) / r.Step
+	} else {
+		n = (r.Start - end - r.Step - 1) / -r.Step
+	}
+
+	if n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// At returns the integer at the index, ok is false if the index is out of the range
+func (r *Range) At(index int) (n int, ok bool) {
+	if index < 0 || !r.Open && index >= r.Len() {
+		return 0, false
+	}
+
+	return r.Start + index*r.Step, true
+}
+
+func (r *Range) Contains(n int) bool {
+	offset := n - r.Start
+	if offset%r.Step != 0 {
+		return false
+	}
+
+	_, ok := r.At(offset / r.Step)
+
+	return ok
+}
+
+// Indexes returns the indexes the range selects in a sequence of the given length, the indexes
+// out of the sequence are left out and an open range goes up to the end of the sequence
+func (r *Range) Indexes(length int) []int {
+	bounded := *r
+	if r.Open {
+		bounded.Open, bounded.Inclusive = false, false
+
+		bounded.End = length
+		if r.Step < 0 {
+			bounded.End = -1
+		}
+	}
+
+	// the first index of the range inside the sequence
+	first := 0
+	if r.Step > 0 && r.Start < 0 {
+		first = (-r.Start + r.Step - 1) / r.Step
+	} else if r.Step < 0 && r.Start >= length {
+		first = (r.Start - length - r.Step) / -r.Step
+	}
+
+	indexes := []int{}
+	for i := first; i < bounded.Len(); i++ {
+		n := r.Start + i*r.Step
+		if n < 0 || n >= length {
+			break
+		}
+
+		indexes = append(indexes, n)
+	}
+
+	return indexes
+}
+
+func (r *Range) String() string {
+	var out bytes.Buffer
+
+	if r.Step != 1 {
+		out.WriteString("(")
+	}
+
+	out.WriteString(fmt.Sprintf("%d", r.Start))
+	switch {
+	case r.Open:
+		out.WriteString("..")
+	case r.Inclusive:
+		out.WriteString(fmt.Sprintf("..=%d", r.End))
+	default:
+		out.WriteString(fmt.Sprintf("..%d", r.End))
+	}
+
+	if r.Step != 1 {
+		out.WriteString(fmt.Sprintf(").step(%d)", r.Step))
+	}
+
+	return out.String()
+}
@@ -1,8 +1,12 @@
 package value
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 	"unsafe"
+
+	"github.com/joetifa2003/windlang/opcode"
 )
 
 type ValueType int
@@ -13,8 +17,40 @@ const (
 	VALUE_NIL
 	VALUE_ARRAY
 	VALUE_OBJECT
+	VALUE_FLOAT
+	VALUE_STRING
+	VALUE_FUNCTION
+	VALUE_BUILTIN
+	VALUE_RANGE
 )
 
+func (vt ValueType) String() string {
+	switch vt {
+	case VALUE_INT:
+		return "INTEGER"
+	case VALUE_BOOL:
+		return "BOOLEAN"
+	case VALUE_NIL:
+		return "NIL"
+	case VALUE_ARRAY:
+		return "ARRAY"
+	case VALUE_OBJECT:
+		return "HASH"
+	case VALUE_FLOAT:
+		return "FLOAT"
+	case VALUE_STRING:
+		return "STRING"
+	case VALUE_FUNCTION:
+		return "FUNCTION"
+	case VALUE_BUILTIN:
+		return "BUILTIN"
+	case VALUE_RANGE:
+		return "RANGE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type Value struct {
 	VType         ValueType
 	primitiveData [8]byte // int64, float64, bool
@@ -22,7 +58,51 @@ type Value struct {
 }
 
 type NonPrimitiveData struct {
-	ArrayV []Value
+	ArrayV    []Value
+	StringV   string
+	ObjectV   map[HashKey]Value
+	FunctionV *Closure
+	BuiltinV  *Builtin
+	RangeV    *Range
+}
+
+// Function is a compiled function, it's stored in the constants
+// and turned into a closure when the code reaches it
+type Function struct {
+	Name         string
+	File         string // the file the function was declared in
+	Arity        int
+	LocalCount   int // the number of variables in the function scope, parameters included
+	Instructions []opcode.OpCode
+	Lines        []int     // the source line of each instruction
+	Upvalues     []Upvalue // the variables the function captures from the enclosing functions
+}
+
+// Upvalue describes how a closure captures a variable, either from the variables
+// of the enclosing function or from the upvalues of the enclosing closure
+type Upvalue struct {
+	Local bool
+	Scope int // scope index of the variable in the enclosing function, only for local upvalues
+	Index int
+}
+
+type Closure struct {
+	Fn       *Function
+	Upvalues []*Value
+	This     Value
+	Imports  map[string]Value // the variables of the modules its file included without an alias, shared by the closures of the file
+}
+
+type Builtin struct {
+	Name string
+	Fn   func(args []Value) (Value, error)
+}
+
+// HashKey is the comparable form of a hashable value, used as the key of objects
+type HashKey struct {
+	VType         ValueType
+	primitiveData [8]byte
+	str           string
 }
 
 func NewNilValue() Value {
@@ -42,6 +122,17 @@ func NewIntValue(v int) Value {
 	return value
 }
 
+func NewFloatValue(v float64) Value {
+	value := Value{
+		VType:         VALUE_FLOAT,
+		primitiveData: [8]byte{},
+	}
+
+	*(*float64)(unsafe.Pointer(&value.primitiveData[0])) = v
+
+	return value
+}
+
 func NewBoolValue(v bool) Value {
 	value := Value{
 		VType:         VALUE_BOOL,
@@ -62,10 +153,60 @@ func NewArrayValue(v []Value) Value {
 	}
 }
 
+func NewStringValue(v string) Value {
+	return Value{
+		VType: VALUE_STRING,
+		nonPrimitive: &NonPrimitiveData{
+			StringV: v,
+		},
+	}
+}
+
+func NewObjectValue(v map[HashKey]Value) Value {
+	return Value{
+		VType: VALUE_OBJECT,
+		nonPrimitive: &NonPrimitiveData{
+			ObjectV: v,
+		},
+	}
+}
+
+func NewFunctionValue(v *Closure) Value {
+	return Value{
+		VType: VALUE_FUNCTION,
+		nonPrimitive: &NonPrimitiveData{
+			FunctionV: v,
+		},
+	}
+}
+
+func NewBuiltinValue(name string, fn func(args []Value) (Value, error)) Value {
+	return Value{
+		VType: VALUE_BUILTIN,
+		nonPrimitive: &NonPrimitiveData{
+			BuiltinV: &Builtin{Name: name, Fn: fn},
+		},
+	}
+}
+
+func NewRangeValue(v *Range) Value {
+	return Value{
+		VType: VALUE_RANGE,
+		nonPrimitive: &NonPrimitiveData{
+			RangeV: v,
+		},
+	}
+}
+
 func (v *Value) GetArray() []Value {
 	return v.nonPrimitive.ArrayV
 }
 
+// SetArray replaces the elements of the array, the change is seen by every copy of the value
+func (v *Value) SetArray(elements []Value) {
+	v.nonPrimitive.ArrayV = elements
+}
+
 func (v *Value) GetInt() int {
 	return *(*int)(unsafe.Pointer(&v.primitiveData[0]))
 }
@@ -74,21 +215,191 @@ func (v *Value) GetIntPtr() *int {
 	return (*int)(unsafe.Pointer(&v.primitiveData[0]))
 }
 
+func (v *Value) GetFloat() float64 {
+	return *(*float64)(unsafe.Pointer(&v.primitiveData[0]))
+}
+
 func (v *Value) GetBool() bool {
 	return *(*bool)(unsafe.Pointer(&v.primitiveData[0]))
 }
 
+func (v *Value) GetString() string {
+	return v.nonPrimitive.StringV
+}
+
+func (v *Value) GetObject() map[HashKey]Value {
+	return v.nonPrimitive.ObjectV
+}
+
+func (v *Value) GetFunction() *Closure {
+	return v.nonPrimitive.FunctionV
+}
+
+func (v *Value) GetBuiltin() *Builtin {
+	return v.nonPrimitive.BuiltinV
+}
+
+func (v *Value) GetRange() *Range {
+	return v.nonPrimitive.RangeV
+}
+
+// HashKey returns the key used to store the value in an object, ok is false if the value can't be a key
+func (v *Value) HashKey() (key HashKey, ok bool) {
+	switch v.VType {
+	case VALUE_INT, VALUE_BOOL:
+		return HashKey{VType: v.VType, primitiveData: v.primitiveData}, true
+	case VALUE_STRING:
+		return HashKey{VType: v.VType, str: v.GetString()}, true
+	}
+
+	return HashKey{}, false
+}
+
+// Value returns the value the key was made from
+func (k HashKey) Value() Value {
+	if k.VType == VALUE_STRING {
+		return NewStringValue(k.str)
+	}
+
+	return Value{VType: k.VType, primitiveData: k.primitiveData}
+}
+
+// Equals compares primitives and strings by value, other values are equal only if they are the same value
+func (v *Value) Equals(other Value) bool {
+	switch {
+	case v.VType == VALUE_INT && other.VType == VALUE_FLOAT:
+		return float64(v.GetInt()) == other.GetFloat()
+	case v.VType == VALUE_FLOAT && other.VType == VALUE_INT:
+		return v.GetFloat() == float64(other.GetInt())
+	case v.VType != other.VType:
+		return false
+	}
+
+	switch v.VType {
+	case VALUE_INT:
+		return v.GetInt() == other.GetInt()
+	case VALUE_FLOAT:
+		return v.GetFloat() == other.GetFloat()
+	case VALUE_BOOL:
+		return v.GetBool() == other.GetBool()
+	case VALUE_NIL:
+		return true
+	case VALUE_STRING:
+		return v.GetString() == other.GetString()
+	default:
+		return v.nonPrimitive == other.nonPrimitive
+	}
+}
+
 func (v *Value) String() string {
 	switch v.VType {
 	case VALUE_INT:
 		return fmt.Sprint(v.GetInt())
+	case VALUE_FLOAT:
+		return fmt.Sprintf("%f", v.GetFloat())
 	case VALUE_BOOL:
 		return fmt.Sprint(v.GetBool())
 	case VALUE_NIL:
 		return "nil"
+	case VALUE_STRING:
+		return v.GetString()
 	case VALUE_ARRAY:
-		return fmt.Sprint(v.GetArray())
+		var out bytes.Buffer
+
+		out.WriteString("[")
+		for _, element := range v.GetArray() {
+			out.WriteString(element.String())
+			out.WriteString(",")
+		}
+		out.WriteString("]")
+
+		return out.String()
+	case VALUE_OBJECT:
+		var out bytes.Buffer
+
+		out.WriteString("{")
+		for _, key := range v.SortedKeys() {
+			keyValue := key.Value()
+			element := v.GetObject()[key]
+
+			out.WriteString(keyValue.String())
+			out.WriteString(": ")
+			out.WriteString(element.String())
+			out.WriteString(", ")
+		}
+		out.WriteString("}")
+
+		return out.String()
+	case VALUE_FUNCTION:
+		return "fn " + v.GetFunction().Fn.Name
+	case VALUE_BUILTIN:
+		return "builtin function"
+	case VALUE_RANGE:
+		return v.GetRange().String()
 	}
 
 	panic("Unimplemented String() for value type")
 }
+
+// SortedKeys returns the keys of an object in a stable order
+func (v *Value) SortedKeys() []HashKey {
+	object := v.GetObject()
+
+	keys := make([]HashKey, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].VType != keys[j].VType {
+			return keys[i].VType < keys[j].VType
+		}
+
+		if keys[i].VType == VALUE_STRING {
+			return keys[i].str < keys[j].str
+		}
+
+		left, right := keys[i].Value(), keys[j].Value()
+		return left.GetInt() < right.GetInt()
+	})
+
+	return keys
+}
+
+// FromInterface converts decoded json to a value
+func FromInterface(v interface{}) Value {
+	switch v := v.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			return NewIntValue(int(v))
+		}
+
+		return NewFloatValue(v)
+
+	case string:
+		return NewStringValue(v)
+
+	case bool:
+		return NewBoolValue(v)
+
+	case []interface{}:
+		res := make([]Value, len(v))
+		for i, val := range v {
+			res[i] = FromInterface(val)
+		}
+
+		return NewArrayValue(res)
+
+	case map[string]interface{}:
+		res := make(map[HashKey]Value, len(v))
+		for k, val := range v {
+			key := NewStringValue(k)
+			hashKey, _ := key.HashKey()
+			res[hashKey] = FromInterface(val)
+		}
+
+		return NewObjectValue(res)
+	}
+
+	return NewNilValue()
+}
@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joetifa2003/windlang/value"
+)
+
+// arrayMethods is set in init because the methods calling functions refer back to it through the vm
+var arrayMethods map[string]*builtin
+
+func init() {
+	arrayMethods = map[string]*builtin{
+		"len": {
+			argsCount: 0,
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				return value.NewIntValue(len(this.GetArray())), nil
+			},
+		},
+		"join": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_STRING},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				strArr := []string{}
+				for _, element := range this.GetArray() {
+					strArr = append(strArr, element.String())
+				}
+
+				return value.NewStringValue(strings.Join(strArr, args[0].GetString())), nil
+			},
+		},
+		"filter": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_FUNCTION},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				filtered := []value.Value{}
+				for _, element := range this.GetArray() {
+					result, err := v.call(args[0], element)
+					if err != nil {
+						return value.Value{}, err
+					}
+
+					if isTrue(result) {
+						filtered = append(filtered, element)
+					}
+				}
+
+				return value.NewArrayValue(filtered), nil
+			},
+		},
+		"map": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_FUNCTION},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				mapped := []value.Value{}
+				for _, element := range this.GetArray() {
+					result, err := v.call(args[0], element)
+					if err != nil {
+						return value.Value{}, err
+					}
+
+					mapped = append(mapped, result)
+				}
+
+				return value.NewArrayValue(mapped), nil
+			},
+		},
+		"reduce": {
+			argsCount: 2,
+			argsTypes: []value.ValueType{value.VALUE_FUNCTION, anyType},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				accumulator := args[1]
+				for _, element := range this.GetArray() {
+					result, err := v.call(args[0], accumulator, element)
+					if err != nil {
+						return value.Value{}, err
+					}
+
+					accumulator = result
+				}
+
+				return accumulator, nil
+			},
+		},
+		"push": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{anyType},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				this.SetArray(append(this.GetArray(), args[0]))
+
+				return this, nil
+			},
+		},
+		"pop": {
+			argsCount: 0,
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				array := this.GetArray()
+				if len(array) == 0 {
+					return value.NewNilValue(), nil
+				}
+
+				this.SetArray(array[:len(array)-1])
+
+				return array[len(array)-1], nil
+			},
+		},
+		"contains": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_FUNCTION},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				for _, element := range this.GetArray() {
+					result, err := v.call(args[0], element)
+					if err != nil {
+						return value.Value{}, err
+					}
+
+					if isTrue(result) {
+						return value.NewBoolValue(true), nil
+					}
+				}
+
+				return value.NewBoolValue(false), nil
+			},
+		},
+		"count": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_FUNCTION},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				count := 0
+				for _, element := range this.GetArray() {
+					result, err := v.call(args[0], element)
+					if err != nil {
+						return value.Value{}, err
+					}
+
+					if isTrue(result) {
+						count++
+					}
+				}
+
+				return value.NewIntValue(count), nil
+			},
+		},
+		"clone": {
+			argsCount: 0,
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				return value.NewArrayValue(append([]value.Value{}, this.GetArray()...)), nil
+			},
+		},
+		"removeAt": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_INT},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				array := this.GetArray()
+				index := args[0].GetInt()
+
+				if index < 0 || index >= len(array) {
+					return value.Value{}, fmt.Errorf("index %d out of bounds", index)
+				}
+
+				removed := array[index]
+				newArray := append([]value.Value{}, array[:index]...)
+				this.SetArray(append(newArray, array[index+1:]...))
+
+				return removed, nil
+			},
+		},
+	}
+}
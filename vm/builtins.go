@@ -0,0 +1,132 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joetifa2003/windlang/value"
+)
+
+// anyType accepts an argument of any type
+const anyType value.ValueType = -1
+
+// builtin is a function implemented in go, methods get the value they are called on as this
+type builtin struct {
+	argsCount int // -1 for any number of arguments
+	argsTypes []value.ValueType
+	fn        func(v *VM, this value.Value, args []value.Value) (value.Value, error)
+}
+
+func (b *builtin) call(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+	if b.argsCount != -1 && len(args) != b.argsCount {
+		return value.Value{}, fmt.Errorf("expected %d arg(s) got %d", b.argsCount, len(args))
+	}
+
+	for i, t := range b.argsTypes {
+		if i < len(args) && t != anyType && args[i].VType != t {
+			return value.Value{}, fmt.Errorf("expected arg %d to be of type %s got %s", i, t, args[i].VType)
+		}
+	}
+
+	return b.fn(v, this, args)
+}
+
+// bind returns the builtin as a value that can be called by the vm
+func (b *builtin) bind(v *VM, name string, this value.Value) value.Value {
+	return value.NewBuiltinValue(name, func(args []value.Value) (value.Value, error) {
+		return b.call(v, this, args)
+	})
+}
+
+var builtins = map[string]*builtin{
+	"println": {
+		argsCount: -1,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			argsString := []interface{}{}
+			for _, arg := range args {
+				argsString = append(argsString, arg.String())
+			}
+
+			fmt.Fprintln(v.stdout, argsString...)
+
+			return value.NewNilValue(), nil
+		},
+	},
+	"print": {
+		argsCount: -1,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			argsString := []interface{}{}
+			for _, arg := range args {
+				argsString = append(argsString, arg.String())
+			}
+
+			fmt.Fprint(v.stdout, argsString...)
+
+			return value.NewNilValue(), nil
+		},
+	},
+	"string": {
+		argsCount: 1,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			switch args[0].VType {
+			case value.VALUE_INT:
+				return value.NewStringValue(fmt.Sprintf("%d", args[0].GetInt())), nil
+			case value.VALUE_FLOAT:
+				return value.NewStringValue(fmt.Sprintf("%f", args[0].GetFloat())), nil
+			}
+
+			return value.Value{}, fmt.Errorf("argument to `string` not supported")
+		},
+	},
+	"input": {
+		argsCount: -1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			if len(args) != 0 {
+				fmt.Fprint(v.stdout, args[0].String())
+			}
+
+			line, _ := v.stdin.ReadString('\n')
+
+			return value.NewStringValue(strings.TrimRight(line, "\r\n")), nil
+		},
+	},
+}
+
+func (v *VM) newGlobals() map[string]value.Value {
+	globals := map[string]value.Value{}
+	for name, b := range builtins {
+		globals[name] = b.bind(v, name, value.NewNilValue())
+	}
+
+	return globals
+}
+
+// method returns the method of an array, a string or a range bound to it
+func (v *VM) method(this value.Value, name value.Value) (value.Value, error) {
+	if name.VType != value.VALUE_STRING {
+		return value.Value{}, fmt.Errorf("cannot use %s as an index", name.VType)
+	}
+
+	var methods map[string]*builtin
+	switch this.VType {
+	case value.VALUE_ARRAY:
+		methods = arrayMethods
+	case value.VALUE_STRING:
+		methods = stringMethods
+	case value.VALUE_RANGE:
+		methods = rangeMethods
+	}
+
+	method, ok := methods[name.GetString()]
+	if !ok {
+		return value.Value{}, fmt.Errorf("cannot find '%s' function on type %s", name.GetString(), this.VType)
+	}
+
+	return method.bind(v, name.GetString(), this), nil
+}
+
+// isTrue is the check the evaluator uses on the results of callbacks
+func isTrue(v value.Value) bool {
+	return v.VType == value.VALUE_BOOL && v.GetBool()
+}
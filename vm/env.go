@@ -10,6 +10,10 @@ type Environment struct {
 
 func NewEnvironment(varCount int) Environment {
 	store := make([]value.Value, varCount)
+	for i := range store {
+		store[i] = value.NewNilValue()
+	}
+
 	return Environment{
 		Store: store,
 	}
@@ -22,7 +26,7 @@ type EnvironmentStack struct {
 
 func NewEnvironmentStack() EnvironmentStack {
 	return EnvironmentStack{
-		Value: make([]Environment, 2048),
+		Value: make([]Environment, StackSize),
 	}
 }
 
@@ -33,9 +37,16 @@ func (s *EnvironmentStack) pop() Environment {
 	return lastEle
 }
 
-func (s *EnvironmentStack) push(env Environment) {
+// push returns false if the stack is full
+func (s *EnvironmentStack) push(env Environment) bool {
+	if s.p == len(s.Value) {
+		return false
+	}
+
 	s.Value[s.p] = env
 	s.p++
+
+	return true
 }
 
 func (s *EnvironmentStack) let(index int, val value.Value) {
@@ -57,15 +68,20 @@ func (s *EnvironmentStack) set(scopeIndex, index int, value value.Value) value.V
 	return env.Store[index]
 }
 
+// increment adds one to the variable in place, ok is false if the variable isn't a number
 func (s *EnvironmentStack) increment(scopeIndex, index int) (ok bool) {
 	env := &s.Value[scopeIndex]
 	val := &env.Store[index]
-	if val.VType != value.VALUE_INT {
+
+	switch val.VType {
+	case value.VALUE_INT:
+		ptr := val.GetIntPtr()
+		*ptr++
+	case value.VALUE_FLOAT:
+		*val = value.NewFloatValue(val.GetFloat() + 1)
+	default:
 		return false
 	}
 
-	ptr := val.GetIntPtr()
-	*ptr++
-
 	return true
 }
@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/sandbox"
+	"github.com/joetifa2003/windlang/value"
+)
+
+// RuntimeError is an error that happened while running the bytecode, it records the instruction that failed
+type RuntimeError struct {
+	Message string
+	File    string
+	Line    int
+	Offset  int           // the offset of the failed instruction in its function
+	Op      opcode.OpCode // the failed instruction
+	Stack   []StackFrame  // the calls that led to the error, most recent first
+	Value   *value.Value  // the value passed to throw, nil for runtime errors
+	Err     error         // the error that stopped the program for errors it can't catch, see Fatal
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("[file %s:%d] %s (%s at %04d)", e.File, e.Line, e.Message, e.Op, e.Offset)
+}
+
+// Unwrap returns the error that stopped the program, nil for errors it can catch
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Fatal reports whether the program can't catch the error because it went over its limits or it was cancelled
+func (e *RuntimeError) Fatal() bool {
+	return sandbox.IsFatal(e.Err)
+}
+
+// StackTrace returns the calls that led to the error, one per line
+func (e *RuntimeError) StackTrace() string {
+	var out bytes.Buffer
+
+	out.WriteString("stack trace:")
+	for _, frame := range e.Stack {
+		out.WriteString("\n  at ")
+		out.WriteString(frame.String())
+	}
+
+	return out.String()
+}
+
+// StackFrame is the location reached inside a function when an error happened
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+func (sf StackFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", sf.Function, sf.File, sf.Line)
+}
+
+// errorValue returns the value a catch block receives for the error,
+// thrown values are passed as is and runtime errors are described by a hash
+func errorValue(err *RuntimeError) value.Value {
+	if err.Value != nil {
+		return *err.Value
+	}
+
+	hash := value.NewObjectValue(map[value.HashKey]value.Value{})
+	set := func(key string, val value.Value) {
+		k := value.NewStringValue(key)
+		hashKey, _ := k.HashKey()
+		hash.GetObject()[hashKey] = val
+	}
+
+	set("message", value.NewStringValue(err.Message))
+	set("file", value.NewStringValue(err.File))
+	set("line", value.NewIntValue(err.Line))
+
+	return hash
+}
@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/value"
+)
+
+var operators = map[opcode.OpCode]string{
+	opcode.OP_ADD:       "+",
+	opcode.OP_SUBTRACT:  "-",
+	opcode.OP_MULTIPLY:  "*",
+	opcode.OP_DIVIDE:    "/",
+	opcode.OP_MODULO:    "%",
+	opcode.OP_LESS:      "<",
+	opcode.OP_LESSEQ:    "<=",
+	opcode.OP_GREATER:   ">",
+	opcode.OP_GREATEREQ: ">=",
+	opcode.OP_EQ:        "==",
+	opcode.OP_NOTEQ:     "!=",
+	opcode.OP_AND:       "&&",
+	opcode.OP_OR:        "||",
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// binaryOp applies an infix operator following the rules of the evaluator,
+// integers mixed with floats are converted to floats and other mixed types are an error
+func binaryOp(op opcode.OpCode, left, right value.Value) (value.Value, error) {
+	switch {
+	case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
+		if (op == opcode.OP_DIVIDE || op == opcode.OP_MODULO) && right.GetInt() == 0 {
+			return value.Value{}, errDivisionByZero
+		}
+
+		if result, ok := intOp(op, left.GetInt(), right.GetInt()); ok {
+			return result, nil
+		}
+
+	case left.VType == value.VALUE_FLOAT && right.VType == value.VALUE_FLOAT:
+		if result, ok := floatOp(op, left.GetFloat(), right.GetFloat()); ok {
+			return result, nil
+		}
+
+	case left.VType == value.VALUE_FLOAT && right.VType == value.VALUE_INT:
+		if result, ok := floatOp(op, left.GetFloat(), float64(right.GetInt())); ok {
+			return result, nil
+		}
+
+	case left.VType == value.VALUE_INT && right.VType == value.VALUE_FLOAT:
+		if result, ok := floatOp(op, float64(left.GetInt()), right.GetFloat()); ok {
+			return result, nil
+		}
+
+	case left.VType == value.VALUE_STRING && right.VType == value.VALUE_STRING:
+		if op == opcode.OP_ADD {
+			return value.NewStringValue(left.GetString() + right.GetString()), nil
+		}
+	}
+
+	switch op {
+	case opcode.OP_EQ:
+		return value.NewBoolValue(left.Equals(right)), nil
+	case opcode.OP_NOTEQ:
+		return value.NewBoolValue(!left.Equals(right)), nil
+	case opcode.OP_AND:
+		return value.NewBoolValue(isTruthy(left) && isTruthy(right)), nil
+	case opcode.OP_OR:
+		return value.NewBoolValue(isTruthy(left) || isTruthy(right)), nil
+	}
+
+	if left.VType != right.VType {
+		return value.Value{}, fmt.Errorf("unsupported operands %s %s %s", left.VType, operators[op], right.VType)
+	}
+
+	return value.Value{}, fmt.Errorf("unknown operator: %s %s %s", left.String(), operators[op], right.String())
+}
+
+func intOp(op opcode.OpCode, left, right int) (value.Value, bool) {
+	switch op {
+	case opcode.OP_ADD:
+		return value.NewIntValue(left + right), true
+	case opcode.OP_SUBTRACT:
+		return value.NewIntValue(left - right), true
+	case opcode.OP_MULTIPLY:
+		return value.NewIntValue(left * right), true
+	case opcode.OP_DIVIDE:
+		return value.NewIntValue(left / right), true
+	case opcode.OP_MODULO:
+		return value.NewIntValue(left % right), true
+	case opcode.OP_LESS:
+		return value.NewBoolValue(left < right), true
+	case opcode.OP_LESSEQ:
+		return value.NewBoolValue(left <= right), true
+	case opcode.OP_GREATER:
+		return value.NewBoolValue(left > right), true
+	case opcode.OP_GREATEREQ:
+		return value.NewBoolValue(left >= right), true
+	case opcode.OP_EQ:
+		return value.NewBoolValue(left == right), true
+	case opcode.OP_NOTEQ:
+		return value.NewBoolValue(left != right), true
+	}
+
+	return value.Value{}, false
+}
+
+func floatOp(op opcode.OpCode, left, right float64) (value.Value, bool) {
+	switch op {
+	case opcode.OP_ADD:
+		return value.NewFloatValue(left + right), true
+	case opcode.OP_SUBTRACT:
+		return value.NewFloatValue(left - right), true
+	case opcode.OP_MULTIPLY:
+		return value.NewFloatValue(left * right), true
+	case opcode.OP_DIVIDE:
+		return value.NewFloatValue(left / right), true
+	case opcode.OP_MODULO:
+		return value.NewFloatValue(math.Mod(left, right)), true
+	case opcode.OP_LESS:
+		return value.NewBoolValue(left < right), true
+	case opcode.OP_LESSEQ:
+		return value.NewBoolValue(left <= right), true
+	case opcode.OP_GREATER:
+		return value.NewBoolValue(left > right), true
+	case opcode.OP_GREATEREQ:
+		return value.NewBoolValue(left >= right), true
+	case opcode.OP_EQ:
+		return value.NewBoolValue(left == right), true
+	case opcode.OP_NOTEQ:
+		return value.NewBoolValue(left != right), true
+	}
+
+	return value.Value{}, false
+}
@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/joetifa2003/windlang/value"
+)
+
+var rangeMethods = map[string]*builtin{
+	"len": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			r := this.GetRange()
+			if r.Open {
+				return value.Value{}, fmt.Errorf("an open range has no length")
+			}
+
+			return value.NewIntValue(r.Len()), nil
+		},
+	},
+	"contains": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{anyType},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewBoolValue(args[0].VType == value.VALUE_INT && this.GetRange().Contains(args[0].GetInt())), nil
+		},
+	},
+	"step": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_INT},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			step := args[0].GetInt()
+			if step == 0 {
+				return value.Value{}, fmt.Errorf("the step of a range cannot be 0")
+			}
+
+			stepped := *this.GetRange()
+			stepped.Step = step
+
+			return value.NewRangeValue(&stepped), nil
+		},
+	},
+	"toArray": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			r := this.GetRange()
+			if r.Open {
+				return value.Value{}, fmt.Errorf("an open range cannot be converted to an array")
+			}
+
+			n := r.Len()
+			if err := v.budget.ArraySize(n); err != nil {
+				return value.Value{}, err
+			}
+
+			elements := make([]value.Value, n)
+			for i := range elements {
+				elements[i] = value.NewIntValue(r.Start + i*r.Step)
+			}
+
+			return value.NewArrayValue(elements), nil
+		},
+	},
+}
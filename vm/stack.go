@@ -1,16 +1,24 @@
 package vm
 
 import (
+	"errors"
+
 	"github.com/joetifa2003/windlang/value"
 )
 
+// StackSize is the number of values and environments the vm can hold,
+// going over it is a stack overflow
+const StackSize = 2048
+
+var errStackOverflow = errors.New("stack overflow")
+
 type Stack struct {
 	Value []value.Value
 }
 
 func NewStack() Stack {
 	return Stack{
-		Value: make([]value.Value, 0, 2048),
+		Value: make([]value.Value, 0, StackSize),
 	}
 }
 
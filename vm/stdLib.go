@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+
+	"github.com/joetifa2003/windlang/value"
+)
+
+var stdLib = map[string]map[string]*builtin{
+	"math": {
+		"abs": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_FLOAT},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				return value.NewFloatValue(math.Abs(args[0].GetFloat())), nil
+			},
+		},
+	},
+	"request": {
+		"get": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_STRING},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				if err := v.permissions.CheckNet(args[0].GetString()); err != nil {
+					return value.Value{}, err
+				}
+
+				resp, err := http.Get(args[0].GetString())
+				if err != nil {
+					return value.Value{}, fmt.Errorf("get request failed")
+				}
+				defer resp.Body.Close()
+
+				respBytes, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					return value.Value{}, fmt.Errorf("get request failed")
+				}
+
+				result := make(map[string]interface{})
+				json.Unmarshal(respBytes, &result)
+
+				return value.FromInterface(result), nil
+			},
+		},
+	},
+	"fs": {
+		"readFile": {
+			argsCount: 1,
+			argsTypes: []value.ValueType{value.VALUE_STRING},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				path := args[0].GetString()
+				if err := v.permissions.CheckFile(path); err != nil {
+					return value.Value{}, err
+				}
+
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return value.Value{}, fmt.Errorf("cannot read file: %s", path)
+				}
+
+				return value.NewStringValue(string(content)), nil
+			},
+		},
+		"writeFile": {
+			argsCount: 2,
+			argsTypes: []value.ValueType{value.VALUE_STRING, value.VALUE_STRING},
+			fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+				path := args[0].GetString()
+				if err := v.permissions.CheckFile(path); err != nil {
+					return value.Value{}, err
+				}
+
+				if err := os.WriteFile(path, []byte(args[1].GetString()), 0644); err != nil {
+					return value.Value{}, fmt.Errorf("cannot write file: %s", path)
+				}
+
+				return value.NewNilValue(), nil
+			},
+		},
+	},
+}
+
+// stdlib returns a std library as a hash of its functions
+func (v *VM) stdlib(name string) (value.Value, bool) {
+	library, ok := stdLib[name]
+	if !ok {
+		return value.Value{}, false
+	}
+
+	module := map[value.HashKey]value.Value{}
+	for fnName, fn := range library {
+		key := value.NewStringValue(fnName)
+		hashKey, _ := key.HashKey()
+		module[hashKey] = fn.bind(v, fnName, value.NewNilValue())
+	}
+
+	return value.NewObjectValue(module), true
+}
@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joetifa2003/windlang/value"
+)
+
+var stringMethods = map[string]*builtin{
+	"len": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewIntValue(len(this.GetString())), nil
+		},
+	},
+	"charAt": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_INT},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			runes := []rune(this.GetString())
+			index := args[0].GetInt()
+
+			if index < 0 || index >= len(runes) {
+				return value.NewNilValue(), nil
+			}
+
+			return value.NewStringValue(string(runes[index])), nil
+		},
+	},
+	"contains": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewBoolValue(strings.Contains(this.GetString(), args[0].GetString())), nil
+		},
+	},
+	"containsAny": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewBoolValue(strings.ContainsAny(this.GetString(), args[0].GetString())), nil
+		},
+	},
+	"count": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewIntValue(strings.Count(this.GetString(), args[0].GetString())), nil
+		},
+	},
+	"replace": {
+		argsCount: 2,
+		argsTypes: []value.ValueType{value.VALUE_STRING, value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.Replace(this.GetString(), args[0].GetString(), args[1].GetString(), 1)), nil
+		},
+	},
+	"replaceN": {
+		argsCount: 3,
+		argsTypes: []value.ValueType{value.VALUE_STRING, value.VALUE_STRING, value.VALUE_INT},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.Replace(this.GetString(), args[0].GetString(), args[1].GetString(), args[2].GetInt())), nil
+		},
+	},
+	"replaceAll": {
+		argsCount: 2,
+		argsTypes: []value.ValueType{value.VALUE_STRING, value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.ReplaceAll(this.GetString(), args[0].GetString(), args[1].GetString())), nil
+		},
+	},
+	"toLowerCase": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.ToLower(this.GetString())), nil
+		},
+	},
+	"toUpperCase": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.ToUpper(this.GetString())), nil
+		},
+	},
+	"indexOf": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewIntValue(strings.Index(this.GetString(), args[0].GetString())), nil
+		},
+	},
+	"lastIndexOf": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewIntValue(strings.LastIndex(this.GetString(), args[0].GetString())), nil
+		},
+	},
+	"changeAt": {
+		argsCount: 2,
+		argsTypes: []value.ValueType{value.VALUE_INT, value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			str := this.GetString()
+			index := args[0].GetInt()
+			newValue := args[1].GetString()
+
+			if index < 0 || index >= len(str) {
+				return value.Value{}, fmt.Errorf("index out of range: got %d max %d", index, len(str)-1)
+			}
+
+			if len(newValue) > 1 {
+				return value.Value{}, fmt.Errorf("new value can be at most one character")
+			}
+
+			return value.NewStringValue(str[:index] + newValue + str[index+1:]), nil
+		},
+	},
+	"trim": {
+		argsCount: 0,
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			return value.NewStringValue(strings.TrimSpace(this.GetString())), nil
+		},
+	},
+	"split": {
+		argsCount: 1,
+		argsTypes: []value.ValueType{value.VALUE_STRING},
+		fn: func(v *VM, this value.Value, args []value.Value) (value.Value, error) {
+			elements := []value.Value{}
+			for _, str := range strings.Split(this.GetString(), args[0].GetString()) {
+				elements = append(elements, value.NewStringValue(str))
+			}
+
+			return value.NewArrayValue(elements), nil
+		},
+	},
+}
@@ -1,121 +1,180 @@
 package vm
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
+	"github.com/joetifa2003/windlang/bytecode"
 	"github.com/joetifa2003/windlang/opcode"
+	"github.com/joetifa2003/windlang/sandbox"
 	"github.com/joetifa2003/windlang/value"
 )
 
 type VM struct {
-	Stack     Stack
-	EnvStack  EnvironmentStack
-	Constants []value.Value
+	Stack       Stack
+	EnvStack    EnvironmentStack
+	Constants   []value.Value
+	frames      []frame
+	handlers    []handler              // the active try handlers, innermost last
+	globals     map[string]value.Value // the builtins
+	modules     map[int]value.Value    // the included modules by the index of their constant
+	Trace       io.Writer              // if set, each instruction is written before it runs with the state of the stacks
+	stdout      io.Writer              // where echo and the print builtins write
+	stdin       *bufio.Reader          // where the input builtin reads from
+	budget      *sandbox.Counter
+	permissions *sandbox.Permissions // what the program can access, nil allows everything
 }
 
-func NewVM(constants []value.Value) VM {
+// frame is a function being executed
+type frame struct {
+	closure     *value.Closure
+	ip          int
+	envBase     int // the size of the environment stack before the call, scope indexes are relative to it
+	stackBase   int // the size of the value stack before the call
+	handlerBase int // the number of try handlers before the call
+	module      int // the constant index of the module the frame is including, -1 for function calls
+}
+
+// handler is where execution continues when a value is thrown inside a try block
+type handler struct {
+	ip          int // the first instruction of the handler
+	stackSize   int // the size of the value stack when the try block started
+	envStackPtr int // the size of the environment stack when the try block started
+	frameCount  int // the number of frames when the try block started
+}
+
+func NewVM(constants []value.Value) *VM {
 	stack := NewStack()
 	envStack := NewEnvironmentStack()
 
-	return VM{
+	v := &VM{
 		Stack:     stack,
 		EnvStack:  envStack,
 		Constants: constants,
+		modules:   map[int]value.Value{},
+		stdout:    os.Stdout,
+		stdin:     bufio.NewReader(os.Stdin),
+		budget:    sandbox.NewCounter(context.Background(), sandbox.Limits{}),
 	}
+	v.globals = v.newGlobals()
+
+	return v
 }
 
-func (v *VM) Interpret(instructions []opcode.OpCode) {
-	ip := 0
-	for ip < len(instructions) {
-		switch instructions[ip] {
-		case opcode.OP_CONST:
-			ip++
-			value := v.Constants[instructions[ip]]
+// SetStdout makes the program write its output to w instead of the standard output
+func (v *VM) SetStdout(w io.Writer) {
+	v.stdout = w
+}
 
-			v.Stack.push(value)
+// SetStdin makes the program read its input from r instead of the standard input
+func (v *VM) SetStdin(r io.Reader) {
+	if reader, ok := r.(*bufio.Reader); ok {
+		v.stdin = reader
+		return
+	}
 
-		case opcode.OP_ADD:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+	v.stdin = bufio.NewReader(r)
+}
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+// SetPermissions restricts the std library modules, the files and the network the program can access,
+// the files it includes are checked by the compiler
+func (v *VM) SetPermissions(permissions *sandbox.Permissions) {
+	v.permissions = permissions
+}
 
-				v.Stack.push(value.NewIntValue(leftNumber + rightNumber))
-			}
+// SetLimits stops the program when the context is done or when it goes over the limits,
+// the error of Interpret wraps sandbox.ErrCancelled or sandbox.ErrLimitExceeded and can't be caught by the program
+func (v *VM) SetLimits(ctx context.Context, limits sandbox.Limits) {
+	v.budget = sandbox.NewCounter(ctx, limits)
+}
 
-		case opcode.OP_SUBTRACT:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+// Interpret runs the main function of a program, errors that are not caught are returned as a *RuntimeError
+func (v *VM) Interpret(main *value.Function) error {
+	closure := &value.Closure{Fn: main, This: value.NewNilValue(), Imports: map[string]value.Value{}}
+	v.frames = append(v.frames, frame{closure: closure, module: -1})
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+	if err := v.run(0); err != nil {
+		v.frames = v.frames[:0]
+		v.handlers = v.handlers[:0]
+		v.Stack.Value = v.Stack.Value[:0]
+		for v.EnvStack.p > 0 {
+			v.EnvStack.pop()
+		}
 
-				v.Stack.push(value.NewIntValue(leftNumber - rightNumber))
-			}
+		return err
+	}
 
-		case opcode.OP_MULTIPLY:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+	return nil
+}
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+// run executes the top frame until the number of frames drops to base,
+// builtins that call functions run the vm again on top of the current frame
+func (v *VM) run(base int) error {
+	fr := &v.frames[len(v.frames)-1]
+	instructions := fr.closure.Fn.Instructions
+	ip := fr.ip
 
-				v.Stack.push(value.NewIntValue(leftNumber * rightNumber))
-			}
+	for {
+		if ip >= len(instructions) {
+			// only the main program runs past the end of its instructions
+			v.frames = v.frames[:len(v.frames)-1]
 
-		case opcode.OP_MODULO:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+			return nil
+		}
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+		if err := v.budget.Step(); err != nil {
+			fr.ip = ip
+			return v.runtimeError(err)
+		}
 
-				v.Stack.push(value.NewIntValue(leftNumber % rightNumber))
-			}
+		if v.Trace != nil {
+			v.trace(fr, ip)
+		}
 
-		case opcode.OP_DIVIDE:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+		start := ip
+		var err error
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+		switch instructions[ip] {
+		case opcode.OP_CONST:
+			ip++
+			value := v.Constants[instructions[ip]]
 
-				v.Stack.push(value.NewIntValue(leftNumber / rightNumber))
-			}
+			v.Stack.push(value)
 
-		case opcode.OP_EQ:
+		case opcode.OP_ADD, opcode.OP_SUBTRACT, opcode.OP_MULTIPLY, opcode.OP_DIVIDE, opcode.OP_MODULO,
+			opcode.OP_LESS, opcode.OP_LESSEQ, opcode.OP_GREATER, opcode.OP_GREATEREQ,
+			opcode.OP_EQ, opcode.OP_NOTEQ, opcode.OP_AND, opcode.OP_OR:
 			right := v.Stack.pop()
 			left := v.Stack.pop()
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
-
-				v.Stack.push(value.NewBoolValue(leftNumber == rightNumber))
+			var result value.Value
+			result, err = binaryOp(instructions[ip], left, right)
+			if err == nil {
+				err = v.checkSize(result)
 			}
+			v.Stack.push(result)
 
-		case opcode.OP_LESSEQ:
-			right := v.Stack.pop()
-			left := v.Stack.pop()
+		case opcode.OP_NOT:
+			operand := v.Stack.pop()
 
-			switch {
-			case left.VType == value.VALUE_INT && right.VType == value.VALUE_INT:
-				leftNumber := left.GetInt()
-				rightNumber := right.GetInt()
+			v.Stack.push(value.NewBoolValue(!isTruthy(operand)))
 
-				v.Stack.push(value.NewBoolValue(leftNumber <= rightNumber))
+		case opcode.OP_NEGATE:
+			operand := v.Stack.pop()
+
+			switch operand.VType {
+			case value.VALUE_INT:
+				v.Stack.push(value.NewIntValue(-operand.GetInt()))
+			case value.VALUE_FLOAT:
+				v.Stack.push(value.NewFloatValue(-operand.GetFloat()))
+			default:
+				err = fmt.Errorf("unknown operator: -%s", operand.String())
 			}
 
 		case opcode.OP_JUMP_FALSE:
@@ -141,7 +200,9 @@ func (v *VM) Interpret(instructions []opcode.OpCode) {
 			ip++
 			varCount := int(instructions[ip])
 
-			v.EnvStack.push(NewEnvironment(varCount))
+			if !v.EnvStack.push(NewEnvironment(varCount)) {
+				err = errStackOverflow
+			}
 
 		case opcode.OP_END_BLOCK:
 			v.EnvStack.pop()
@@ -160,7 +221,7 @@ func (v *VM) Interpret(instructions []opcode.OpCode) {
 			ip++
 			index := int(instructions[ip])
 			ip++
-			scopeIndex := int(instructions[ip])
+			scopeIndex := fr.envBase + int(instructions[ip])
 
 			newVal := v.EnvStack.set(scopeIndex, index, value)
 			v.Stack.push(newVal)
@@ -169,20 +230,70 @@ func (v *VM) Interpret(instructions []opcode.OpCode) {
 			ip++
 			index := int(instructions[ip])
 			ip++
-			scopeIndex := int(instructions[ip])
+			scopeIndex := fr.envBase + int(instructions[ip])
 
 			value := v.EnvStack.get(scopeIndex, index)
 			v.Stack.push(value)
 
-		case opcode.OP_POP:
-			if len(v.Stack.Value) != 0 {
-				v.Stack.pop()
+		case opcode.OP_INC:
+			ip++
+			index := int(instructions[ip])
+			ip++
+			scopeIndex := fr.envBase + int(instructions[ip])
+
+			old := v.EnvStack.get(scopeIndex, index)
+			if !v.EnvStack.increment(scopeIndex, index) {
+				err = fmt.Errorf("postfix operator not supported: %s", old.String())
 			}
 
+			v.Stack.push(old)
+
+		case opcode.OP_GET_UPVALUE:
+			ip++
+			index := int(instructions[ip])
+
+			v.Stack.push(*fr.closure.Upvalues[index])
+
+		case opcode.OP_SET_UPVALUE:
+			value := v.Stack.pop()
+
+			ip++
+			index := int(instructions[ip])
+
+			*fr.closure.Upvalues[index] = value
+			v.Stack.push(value)
+
+		case opcode.OP_GET_BUILTIN:
+			ip++
+			name := v.Constants[instructions[ip]]
+
+			value, ok := fr.closure.Imports[name.GetString()]
+			if !ok {
+				value, ok = v.globals[name.GetString()]
+			}
+			if !ok {
+				err = fmt.Errorf("identifier not found: %s", name.GetString())
+			}
+
+			v.Stack.push(value)
+
+		case opcode.OP_THIS:
+			v.Stack.push(fr.closure.This)
+
+		case opcode.OP_POP:
+			v.Stack.pop()
+
+		case opcode.OP_DUP:
+			v.Stack.push(v.Stack.Value[len(v.Stack.Value)-1])
+
+		case opcode.OP_DUP2:
+			v.Stack.push(v.Stack.Value[len(v.Stack.Value)-2])
+			v.Stack.push(v.Stack.Value[len(v.Stack.Value)-2])
+
 		case opcode.OP_ECHO:
 			operand := v.Stack.pop()
 
-			fmt.Println(operand.String())
+			fmt.Fprintln(v.stdout, operand.String())
 
 		case opcode.OP_ARRAY:
 			ip++
@@ -194,30 +305,644 @@ func (v *VM) Interpret(instructions []opcode.OpCode) {
 			}
 
 			v.Stack.push(value.NewArrayValue(values))
+			err = v.budget.ArraySize(n)
 
-		case opcode.OP_INC:
+		case opcode.OP_TEMPLATE:
+			ip++
+			n := int(instructions[ip])
+			parts := make([]string, n)
+
+			for i := n - 1; i >= 0; i-- {
+				part := v.Stack.pop()
+				parts[i] = part.String()
+			}
+
+			result := value.NewStringValue(strings.Join(parts, ""))
+			v.Stack.push(result)
+			err = v.checkSize(result)
+
+		case opcode.OP_HASH:
+			v.Stack.push(value.NewObjectValue(map[value.HashKey]value.Value{}))
+
+		case opcode.OP_HASH_SET, opcode.OP_HASH_METHOD:
+			val := v.Stack.pop()
+			key := v.Stack.pop()
+			hash := v.Stack.Value[len(v.Stack.Value)-1]
+
+			hashKey, ok := key.HashKey()
+			if !ok {
+				err = fmt.Errorf("unusable as hash key: %s", key.String())
+				break
+			}
+
+			if instructions[ip] == opcode.OP_HASH_METHOD {
+				val.GetFunction().This = hash
+			}
+
+			hash.GetObject()[hashKey] = val
+
+		case opcode.OP_INDEX:
+			index := v.Stack.pop()
+			left := v.Stack.pop()
+
+			var result value.Value
+			result, err = v.index(left, index)
+			v.Stack.push(result)
+
+		case opcode.OP_SET_INDEX:
+			val := v.Stack.pop()
+			index := v.Stack.pop()
+			left := v.Stack.pop()
+
+			err = setIndex(left, index, val)
+			v.Stack.push(val)
+
+		case opcode.OP_CLOSURE:
+			ip++
+			fn := v.Constants[instructions[ip]].GetFunction().Fn
+
+			closure := &value.Closure{
+				Fn:       fn,
+				Upvalues: make([]*value.Value, len(fn.Upvalues)),
+				This:     fr.closure.This,
+				Imports:  fr.closure.Imports,
+			}
+
+			for i, upvalue := range fn.Upvalues {
+				if upvalue.Local {
+					closure.Upvalues[i] = &v.EnvStack.Value[fr.envBase+upvalue.Scope].Store[upvalue.Index]
+				} else {
+					closure.Upvalues[i] = fr.closure.Upvalues[upvalue.Index]
+				}
+			}
+
+			v.Stack.push(value.NewFunctionValue(closure))
+
+		case opcode.OP_CALL:
+			ip++
+			argsCount := int(instructions[ip])
+
+			fr.ip = ip + 1
+			err = v.callValue(argsCount)
+			if err != nil {
+				break
+			}
+
+			fr = &v.frames[len(v.frames)-1]
+			instructions = fr.closure.Fn.Instructions
+			ip = fr.ip
+
+			continue
+
+		case opcode.OP_RETURN:
+			result := v.Stack.pop()
+
+			v.handlers = v.handlers[:fr.handlerBase]
+			for v.EnvStack.p > fr.envBase {
+				v.EnvStack.pop()
+			}
+			v.Stack.Value = v.Stack.Value[:fr.stackBase]
+			v.Stack.push(result)
+
+			if fr.module != -1 {
+				v.modules[fr.module] = result
+			}
+
+			v.frames = v.frames[:len(v.frames)-1]
+			if len(v.frames) <= base {
+				return nil
+			}
+
+			fr = &v.frames[len(v.frames)-1]
+			instructions = fr.closure.Fn.Instructions
+			ip = fr.ip
+
+			continue
+
+		case opcode.OP_INCLUDE:
 			ip++
 			index := int(instructions[ip])
+
+			if module, ok := v.modules[index]; ok {
+				v.Stack.push(module)
+				break
+			}
+
+			constant := v.Constants[index]
+			if constant.VType == value.VALUE_STRING {
+				if err = v.permissions.CheckModule(constant.GetString()); err != nil {
+					break
+				}
+
+				module, ok := v.stdlib(constant.GetString())
+				if !ok {
+					err = fmt.Errorf("cannot read file: %s", constant.GetString())
+					break
+				}
+
+				v.modules[index] = module
+				v.Stack.push(module)
+				break
+			}
+
+			fr.ip = ip + 1
+			closure := &value.Closure{Fn: constant.GetFunction().Fn, This: value.NewNilValue(), Imports: map[string]value.Value{}}
+			if err = v.pushFrame(closure, nil, len(v.Stack.Value), index); err != nil {
+				break
+			}
+
+			fr = &v.frames[len(v.frames)-1]
+			instructions = fr.closure.Fn.Instructions
+			ip = fr.ip
+
+			continue
+
+		case opcode.OP_IMPORT:
+			module := v.Stack.pop()
+
+			for key, val := range module.GetObject() {
+				name := key.Value()
+				fr.closure.Imports[name.GetString()] = val
+			}
+
+		case opcode.OP_EXPORT:
+			module := v.Stack.pop()
+
 			ip++
-			scopeIndex := int(instructions[ip])
+			name := v.Constants[instructions[ip]]
+			key, _ := name.HashKey()
 
-			ok := v.EnvStack.increment(scopeIndex, index)
+			val, ok := module.GetObject()[key]
 			if !ok {
-				panic("")
+				err = fmt.Errorf("include key not found: %s", name.GetString())
 			}
 
+			v.Stack.push(val)
+
+		case opcode.OP_ITER:
+			iterable := v.Stack.pop()
+
+			var steps value.Value
+			steps, err = iterationSteps(iterable)
+			v.Stack.push(steps)
+
+		case opcode.OP_NEXT:
+			index := v.Stack.pop()
+			steps := v.Stack.pop()
+			iterable := v.Stack.pop()
+
+			ip++
+			offset := int(instructions[ip])
+
+			i := index.GetInt()
+
+			// a range is its own steps, its integers are computed as the loop reaches them
+			if steps.VType == value.VALUE_RANGE {
+				n, ok := steps.GetRange().At(i)
+				if !ok {
+					ip += offset
+
+					continue
+				}
+
+				v.Stack.push(index)
+				v.Stack.push(value.NewIntValue(n))
+
+				break
+			}
+
+			elements := steps.GetArray()
+			if i >= len(elements) {
+				ip += offset
+
+				continue
+			}
+
+			// the steps of a hash are its keys, the steps of the other values are their elements.
+			// The iterable is nil when the loop only declares the value, which is the step itself
+			if iterable.VType == value.VALUE_OBJECT {
+				key, _ := elements[i].HashKey()
+
+				v.Stack.push(elements[i])
+				v.Stack.push(iterable.GetObject()[key])
+			} else {
+				v.Stack.push(index)
+				v.Stack.push(elements[i])
+			}
+
+		case opcode.OP_RANGE:
+			ip++
+			kind := instructions[ip]
+
+			var r value.Value
+			r, err = newRange(&v.Stack, kind)
+			v.Stack.push(r)
+
+		case opcode.OP_TRY:
+			ip++
+			offset := int(instructions[ip])
+
+			v.handlers = append(v.handlers, handler{
+				ip:          ip + offset,
+				stackSize:   len(v.Stack.Value),
+				envStackPtr: v.EnvStack.p,
+				frameCount:  len(v.frames),
+			})
+
+		case opcode.OP_END_TRY:
+			v.handlers = v.handlers[:len(v.handlers)-1]
+
+		case opcode.OP_ERROR:
+			ip++
+			message := v.Constants[instructions[ip]]
+
+			err = errors.New(message.GetString())
+
+		case opcode.OP_THROW:
+			thrown := v.Stack.pop()
+			err = &RuntimeError{Message: "uncaught exception: " + thrown.String(), Value: &thrown}
+
 		default:
-			panic("Unimplemented OpCode " + fmt.Sprint(instructions[ip]))
+			err = fmt.Errorf("unknown opcode %s", instructions[ip])
+		}
+
+		if err == nil && len(v.Stack.Value) > StackSize {
+			err = errStackOverflow
+		}
+
+		if err != nil {
+			v.frames[len(v.frames)-1].ip = start
+			if err := v.throw(v.runtimeError(err), base); err != nil {
+				return err
+			}
+
+			fr = &v.frames[len(v.frames)-1]
+			instructions = fr.closure.Fn.Instructions
+			ip = fr.ip
+
+			continue
 		}
 
 		ip++
 	}
 }
 
+// trace writes the value stack, the environments of the current frame and the instruction at ip
+func (v *VM) trace(fr *frame, ip int) {
+	var out bytes.Buffer
+
+	out.WriteString("          stack:")
+	for _, val := range v.Stack.Value {
+		out.WriteString(" [" + bytecode.Repr(val) + "]")
+	}
+
+	out.WriteString("\n          envs: ")
+	for i := fr.envBase; i < v.EnvStack.p; i++ {
+		vars := []string{}
+		for _, val := range v.EnvStack.Value[i].Store {
+			vars = append(vars, bytecode.Repr(val))
+		}
+
+		out.WriteString(" {" + strings.Join(vars, " ") + "}")
+	}
+
+	out.WriteString("\n")
+	fmt.Fprint(v.Trace, out.String())
+
+	bytecode.DisassembleInstruction(v.Trace, v.Constants, fr.closure.Fn, ip)
+}
+
+// runtimeError adds the location of the failed instruction and the call stack to an error,
+// the ip of the top frame must point to the failed instruction.
+// Errors that already have a location, like the ones of functions called by builtins, are kept as is
+func (v *VM) runtimeError(err error) *RuntimeError {
+	rtErr, ok := err.(*RuntimeError)
+	if ok && rtErr.Stack != nil {
+		return rtErr
+	}
+
+	if !ok {
+		rtErr = &RuntimeError{Message: err.Error()}
+		if sandbox.IsFatal(err) {
+			rtErr.Err = err
+		}
+	}
+
+	for i := len(v.frames) - 1; i >= 0; i-- {
+		fr := v.frames[i]
+		fn := fr.closure.Fn
+
+		// the frames below the top one are stopped after their call instruction
+		ip := fr.ip
+		if i != len(v.frames)-1 {
+			ip--
+		}
+
+		line := 0
+		if ip >= 0 && ip < len(fn.Lines) {
+			line = fn.Lines[ip]
+		}
+
+		if i == len(v.frames)-1 {
+			rtErr.File = fn.File
+			rtErr.Line = line
+			rtErr.Offset = ip
+			rtErr.Op = fn.Instructions[ip]
+		}
+
+		rtErr.Stack = append(rtErr.Stack, StackFrame{Function: fn.Name, File: fn.File, Line: line})
+	}
+
+	return rtErr
+}
+
+// throw continues execution at the innermost try handler of the frames run above base,
+// errors that are not caught above base are returned
+func (v *VM) throw(err *RuntimeError, base int) error {
+	if len(v.handlers) == 0 || err.Fatal() {
+		return err
+	}
+
+	h := v.handlers[len(v.handlers)-1]
+	if h.frameCount <= base {
+		return err
+	}
+
+	v.handlers = v.handlers[:len(v.handlers)-1]
+	v.frames = v.frames[:h.frameCount]
+	v.Stack.Value = v.Stack.Value[:h.stackSize]
+	for v.EnvStack.p > h.envStackPtr {
+		v.EnvStack.pop()
+	}
+
+	v.Stack.push(errorValue(err))
+	v.frames[len(v.frames)-1].ip = h.ip
+
+	return nil
+}
+
+// pushFrame starts executing a closure, the arguments are the first variables of its scope
+func (v *VM) pushFrame(closure *value.Closure, args []value.Value, stackBase int, module int) error {
+	if len(v.Stack.Value) >= StackSize || v.EnvStack.p >= len(v.EnvStack.Value) {
+		return errStackOverflow
+	}
+
+	if err := v.budget.CallDepth(len(v.frames)); err != nil {
+		return err
+	}
+
+	env := NewEnvironment(closure.Fn.LocalCount)
+	copy(env.Store, args)
+
+	v.frames = append(v.frames, frame{
+		closure:     closure,
+		envBase:     v.EnvStack.p,
+		stackBase:   stackBase,
+		handlerBase: len(v.handlers),
+		module:      module,
+	})
+	v.EnvStack.push(env)
+
+	return nil
+}
+
+// callValue calls the value below the arguments on the stack, closures get a new frame
+// and builtins are called right away leaving their result on the stack
+func (v *VM) callValue(argsCount int) error {
+	calleeIndex := len(v.Stack.Value) - 1 - argsCount
+	callee := v.Stack.Value[calleeIndex]
+	args := v.Stack.Value[calleeIndex+1:]
+
+	switch callee.VType {
+	case value.VALUE_FUNCTION:
+		closure := callee.GetFunction()
+		if closure.Fn.Arity != argsCount {
+			return fmt.Errorf("expected %d arg(s) got %d", closure.Fn.Arity, argsCount)
+		}
+
+		if err := v.pushFrame(closure, args, calleeIndex, -1); err != nil {
+			return err
+		}
+		v.Stack.Value = v.Stack.Value[:calleeIndex]
+
+		return nil
+
+	case value.VALUE_BUILTIN:
+		args := append([]value.Value{}, args...)
+		v.Stack.Value = v.Stack.Value[:calleeIndex]
+
+		result, err := callee.GetBuiltin().Fn(args)
+		if err != nil {
+			return err
+		}
+
+		if err := v.checkSize(result); err != nil {
+			return err
+		}
+
+		v.Stack.push(result)
+
+		return nil
+	}
+
+	return fmt.Errorf("not a function: %s", callee.String())
+}
+
+// call calls a function from go and returns its result
+func (v *VM) call(fn value.Value, args ...value.Value) (value.Value, error) {
+	base := len(v.frames)
+
+	v.Stack.push(fn)
+	for _, arg := range args {
+		v.Stack.push(arg)
+	}
+
+	if err := v.callValue(len(args)); err != nil {
+		return value.Value{}, err
+	}
+
+	if len(v.frames) > base {
+		if err := v.run(base); err != nil {
+			v.frames = v.frames[:base]
+
+			return value.Value{}, err
+		}
+	}
+
+	return v.Stack.pop(), nil
+}
+
+func (v *VM) index(left, index value.Value) (value.Value, error) {
+	switch left.VType {
+	case value.VALUE_ARRAY:
+		if index.VType == value.VALUE_RANGE {
+			array := left.GetArray()
+			indexes := index.GetRange().Indexes(len(array))
+			elements := make([]value.Value, len(indexes))
+			for i, idx := range indexes {
+				elements[i] = array[idx]
+			}
+
+			return value.NewArrayValue(elements), nil
+		}
+
+		if index.VType != value.VALUE_INT {
+			return v.method(left, index)
+		}
+
+		array := left.GetArray()
+		idx := index.GetInt()
+		if idx < 0 || idx >= len(array) {
+			return value.NewNilValue(), nil
+		}
+
+		return array[idx], nil
+
+	case value.VALUE_OBJECT:
+		key, ok := index.HashKey()
+		if !ok {
+			return value.Value{}, fmt.Errorf("unusable as hash key: %s", index.String())
+		}
+
+		if val, ok := left.GetObject()[key]; ok {
+			return val, nil
+		}
+
+		return value.NewNilValue(), nil
+
+	case value.VALUE_STRING:
+		if index.VType == value.VALUE_RANGE {
+			runes := []rune(left.GetString())
+			indexes := index.GetRange().Indexes(len(runes))
+			slice := make([]rune, len(indexes))
+			for i, idx := range indexes {
+				slice[i] = runes[idx]
+			}
+
+			return value.NewStringValue(string(slice)), nil
+		}
+
+		return v.method(left, index)
+
+	case value.VALUE_RANGE:
+		if index.VType != value.VALUE_INT {
+			return v.method(left, index)
+		}
+
+		n, ok := left.GetRange().At(index.GetInt())
+		if !ok {
+			return value.NewNilValue(), nil
+		}
+
+		return value.NewIntValue(n), nil
+	}
+
+	return value.Value{}, fmt.Errorf("index operator not supported: %s", left.String())
+}
+
+func setIndex(left, index, val value.Value) error {
+	switch left.VType {
+	case value.VALUE_ARRAY:
+		if index.VType != value.VALUE_INT {
+			return fmt.Errorf("cannot use %s as an index", index.VType.String())
+		}
+
+		array := left.GetArray()
+		idx := index.GetInt()
+		if idx < 0 || idx >= len(array) {
+			return fmt.Errorf("index out of bounds")
+		}
+
+		array[idx] = val
+
+		return nil
+
+	case value.VALUE_OBJECT:
+		key, ok := index.HashKey()
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.String())
+		}
+
+		left.GetObject()[key] = val
+
+		return nil
+	}
+
+	return fmt.Errorf("index operator not supported: %s", left.String())
+}
+
+// newRange pops the bounds of a range of the given kind, both have to be integers
+func newRange(stack *Stack, kind opcode.OpCode) (value.Value, error) {
+	if kind == opcode.RANGE_OPEN {
+		start := stack.pop()
+		if start.VType != value.VALUE_INT {
+			return value.Value{}, fmt.Errorf("range bounds must be integers, got %s..", start.VType.String())
+		}
+
+		return value.NewRangeValue(&value.Range{Start: start.GetInt(), Step: 1, Open: true}), nil
+	}
+
+	end := stack.pop()
+	start := stack.pop()
+	if start.VType != value.VALUE_INT || end.VType != value.VALUE_INT {
+		return value.Value{}, fmt.Errorf("range bounds must be integers, got %s..%s", start.VType.String(), end.VType.String())
+	}
+
+	return value.NewRangeValue(&value.Range{
+		Start:     start.GetInt(),
+		End:       end.GetInt(),
+		Step:      1,
+		Inclusive: kind == opcode.RANGE_INCLUSIVE,
+	}), nil
+}
+
+// iterationSteps returns the array a for-in loop steps over: the array itself,
+// the characters of a string or the keys of a hash in a stable order, a range steps over itself
+func iterationSteps(iterable value.Value) (value.Value, error) {
+	switch iterable.VType {
+	case value.VALUE_ARRAY, value.VALUE_RANGE:
+		return iterable, nil
+
+	case value.VALUE_STRING:
+		runes := []rune(iterable.GetString())
+		chars := make([]value.Value, len(runes))
+		for i, ch := range runes {
+			chars[i] = value.NewStringValue(string(ch))
+		}
+
+		return value.NewArrayValue(chars), nil
+
+	case value.VALUE_OBJECT:
+		keys := iterable.SortedKeys()
+		values := make([]value.Value, len(keys))
+		for i, key := range keys {
+			values[i] = key.Value()
+		}
+
+		return value.NewArrayValue(values), nil
+	}
+
+	return value.Value{}, fmt.Errorf("cannot iterate over %s", iterable.VType.String())
+}
+
+// checkSize returns an error if the value is bigger than the limits allow
+func (v *VM) checkSize(val value.Value) error {
+	switch val.VType {
+	case value.VALUE_ARRAY:
+		return v.budget.ArraySize(len(val.GetArray()))
+	case value.VALUE_STRING:
+		return v.budget.StringSize(len(val.GetString()))
+	}
+
+	return nil
+}
+
 func isTruthy(input value.Value) bool {
 	switch input.VType {
 	case value.VALUE_BOOL:
 		return input.GetBool()
+	case value.VALUE_NIL:
+		return false
 	default:
 		return true
 	}
@@ -0,0 +1,577 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/joetifa2003/windlang/compiler"
+	"github.com/joetifa2003/windlang/evaluator"
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/loader"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
+	"github.com/joetifa2003/windlang/value"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newVM(t *testing.T, input string) (*VM, *value.Function) {
+	parser := parser.New(lexer.New(input), "test.wind")
+	program := parser.ParseProgram()
+	assert.Empty(t, parser.ReportErrors())
+
+	compiler := compiler.NewCompiler("test.wind")
+	main := compiler.Compile(program)
+	assert.Empty(t, compiler.ReportErrors())
+
+	return NewVM(compiler.Constants), main
+}
+
+func interpret(t *testing.T, input string) error {
+	v, main := newVM(t, input)
+
+	return v.Interpret(main)
+}
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+	}{
+		{`echo 1 + 2;`, "", "3\n"},
+		{`println("a", 1); print("b"); print("c");`, "", "a 1\nbc"},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; echo fib(15);`, "", "610\n"},
+		{`let name = input("name: "); let age = input(); echo name + " " + age;`, "wind\r\n3", "name: wind 3\n"},
+		{`echo [1, 2].map(fn(x) { x * 2 });`, "", "[2,4,]\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+		v.SetStdin(strings.NewReader(tt.stdin))
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+}
+
+// evaluate runs the input with the evaluator and returns what it printed
+func evaluate(t *testing.T, input string) string {
+	var out bytes.Buffer
+
+	parser := parser.New(lexer.New(input), "test.wind")
+	program := parser.ParseProgram()
+	assert.Empty(t, parser.ReportErrors())
+
+	ev := evaluator.New(evaluator.NewEnvironmentManager(), "test.wind")
+	ev.SetStdout(&out)
+	_, err := ev.Eval(program, evaluator.NewEnvironment(), nil)
+	assert.Nil(t, err, input)
+
+	return out.String()
+}
+
+func TestMatchesEvaluator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// a closure that captures and changes a variable
+		{`let count = 0; let inc = fn() { count = count + 1; }; inc(); inc(); println(count);`, "2\n"},
+		{`let counter = fn() { let n = 0; fn() { n++; n } }; let c = counter(); c(); c(); println(c(), counter()());`, "3 1\n"},
+		// closures sharing a variable after the function declaring it returned
+		{`let make = fn() { let n = 0; return [fn() { n }, fn(x) { n = n + x; }]; }; let p = make(); p[1](5); p[1](2); println(p[0]());`, "7\n"},
+		{`let outer = fn() { let n = 1; fn() { fn() { n = n * 2; n } } }; let f = outer()(); f(); println(f());`, "4\n"},
+		{`let make = fn(n) { let get = fn() { n }; let set = fn(x) { n = x; }; return { "get": get, "set": set }; }; let a = make(1); let b = make(2); a["set"](10); println(a["get"](), b["get"]());`, "10 2\n"},
+		// recursion
+		{`let fact = fn(n) { if (n <= 1) { return 1; } n * fact(n - 1) }; println(fact(10));`, "3628800\n"},
+		{`let sum = fn(arr, i) { if (i == 4) { 0 } else { arr[i] + sum(arr, i + 1) } }; println(sum([1, 2, 3, 4], 0));`, "10\n"},
+		// methods using this
+		{`let c = {"value": 0, "inc": fn() { this.value++; return this.value; }}; c.inc(); println(c.inc(), c.value);`, "2 2\n"},
+		{`let o = {"n": 3, "double": fn() { this.n * 2 }, "quad": fn() { this.double() * 2 }}; println(o.quad());`, "12\n"},
+		{`let o = {"n": 1, "later": fn() { fn() { this.n } }}; let f = o.later(); o.n = 5; println(f());`, "5\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+		assert.Equal(t, evaluate(t, tt.input), out.String(), tt.input)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+	}{
+		{"let x = 1;\nx + \"a\";", "unsupported operands INTEGER + STRING", 2},
+		{"-true;", "unknown operator: -true", 1},
+		{"let s = \"a\";\ns++;", "postfix operator not supported: a", 2},
+		{"let f = fn() { f() };\nf();", "stack overflow", 1},
+		{"throw 1;", "uncaught exception: 1", 1},
+		{"let f = fn(x) {\n  x();\n};\nf(1);", "not a function: 1", 2},
+		{"let x = 0;\nprintln(1 / x);", "division by zero", 2},
+		{"let x = 0;\nprintln(1 % x);", "division by zero", 2},
+		{"let a = [\n1" + strings.Repeat(", 1", StackSize) + "];", "stack overflow", 2},
+	}
+
+	for _, tt := range tests {
+		err := interpret(t, tt.input)
+
+		rtErr, ok := err.(*RuntimeError)
+		if assert.True(t, ok, tt.input) {
+			assert.Equal(t, tt.message, rtErr.Message, tt.input)
+			assert.Equal(t, tt.line, rtErr.Line, tt.input)
+			assert.Equal(t, "test.wind", rtErr.File, tt.input)
+		}
+	}
+}
+
+func TestRuntimeErrorStack(t *testing.T) {
+	assert := assert.New(t)
+
+	err := interpret(t, "let f = fn() {\n  nil + 1;\n};\nlet g = fn() { f() };\ng();")
+
+	rtErr, ok := err.(*RuntimeError)
+	if assert.True(ok) {
+		assert.Equal([]StackFrame{
+			{Function: "f", File: "test.wind", Line: 2},
+			{Function: "g", File: "test.wind", Line: 4},
+			{Function: "<main>", File: "test.wind", Line: 5},
+		}, rtErr.Stack)
+	}
+}
+
+func TestRuntimeErrorsAreCatchable(t *testing.T) {
+	err := interpret(t, `
+		let caught = nil;
+		try { [1].map(fn(x) { x + "a" }); } catch (e) { caught = e; }
+		if (caught["message"] != "unsupported operands INTEGER + STRING") { throw caught; }
+		caught = nil;
+		try { 1 / 0; } catch (e) { caught = e; }
+		if (caught["message"] != "division by zero") { throw caught; }
+	`)
+
+	assert.Nil(t, err)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		limits  sandbox.Limits
+		message string
+	}{
+		{`while (true) {}`, sandbox.Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+		{`let f = fn(n) { f(n + 1) }; f(0);`, sandbox.Limits{MaxCallDepth: 50}, "limit exceeded: more than 50 nested calls"},
+		{`let a = []; while (true) { a.push(1); }`, sandbox.Limits{MaxArraySize: 100}, "limit exceeded: array of more than 100 elements"},
+		{`let a = (0..1000).toArray();`, sandbox.Limits{MaxArraySize: 100}, "limit exceeded: array of more than 100 elements"},
+		{`let s = "ab"; while (true) { s = s + s; }`, sandbox.Limits{MaxStringSize: 1000}, "limit exceeded: string of more than 1000 bytes"},
+		{`let s = "abc"; let t = "${s}${s}";`, sandbox.Limits{MaxStringSize: 4}, "limit exceeded: string of more than 4 bytes"},
+		{`while (true) { try { while (true) {} } catch (e) {} }`, sandbox.Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+	}
+
+	for _, tt := range tests {
+		v, main := newVM(t, tt.input)
+		v.SetLimits(context.Background(), tt.limits)
+
+		err := v.Interpret(main)
+		assert.ErrorIs(t, err, sandbox.ErrLimitExceeded, tt.input)
+
+		rtErr, ok := err.(*RuntimeError)
+		if assert.True(t, ok, tt.input) {
+			assert.Equal(t, tt.message, rtErr.Message, tt.input)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	v, main := newVM(t, `[1].map(fn(x) { while (true) {} });`)
+	v.SetLimits(ctx, sandbox.Limits{})
+
+	assert.ErrorIs(t, v.Interpret(main), sandbox.ErrCancelled)
+}
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		input   string
+		modules []string
+		message string
+	}{
+		{`include "fs" as fs; fs.readFile("/etc/hostname");`, nil, "permission denied: cannot access /etc/hostname, it's outside the allowed directories"},
+		{`include "request" as request; request.get("http://localhost");`, nil, "permission denied: network access to http://localhost is not allowed"},
+		{`include "math" as math; include "request" as request;`, []string{"math"}, "permission denied: module request is not allowed"},
+	}
+
+	for _, tt := range tests {
+		v, main := newVM(t, tt.input)
+		v.SetPermissions(&sandbox.Permissions{Modules: tt.modules})
+
+		err := v.Interpret(main)
+		assert.ErrorContains(t, err, tt.message, tt.input)
+	}
+
+	var out bytes.Buffer
+	v, main := newVM(t, `
+		try {
+			include "request" as request;
+		} catch (e) {
+			println(e.message);
+		}
+	`)
+	v.SetStdout(&out)
+	v.SetPermissions(&sandbox.Permissions{Modules: []string{}})
+
+	assert.Nil(t, v.Interpret(main))
+	assert.Equal(t, "permission denied: module request is not allowed\n", out.String())
+}
+
+func TestLoader(t *testing.T) {
+	var out bytes.Buffer
+
+	parser := parser.New(lexer.New(`include "./lib.wind" as lib; println(lib.greet("wind"));`), "scripts/main.wind")
+	program := parser.ParseProgram()
+	assert.Empty(t, parser.ReportErrors())
+
+	compiler := compiler.NewCompiler("scripts/main.wind")
+	compiler.SetLoader(loader.NewFS(fstest.MapFS{
+		"scripts/lib.wind": {Data: []byte(`let greet = fn(name) { "hello " + name };`)},
+	}))
+	main := compiler.Compile(program)
+	assert.Empty(t, compiler.ReportErrors())
+
+	v := NewVM(compiler.Constants)
+	v.SetStdout(&out)
+
+	assert.Nil(t, v.Interpret(main))
+	assert.Equal(t, "hello wind\n", out.String())
+}
+
+func TestCircularInclude(t *testing.T) {
+	// the entry file can be given with a ./ prefix that its includes don't have
+	for _, file := range []string{"a.wind", "./a.wind"} {
+		parser := parser.New(lexer.New("let x = 1;\ninclude \"./b.wind\";"), file)
+		program := parser.ParseProgram()
+
+		compiler := compiler.NewCompiler(file)
+		compiler.SetLoader(loader.NewFS(fstest.MapFS{
+			"a.wind": {Data: []byte("let x = 1;\ninclude \"./b.wind\";")},
+			"b.wind": {Data: []byte("include \"./c\";")},
+			"c.wind": {Data: []byte("\n\ninclude \"./a.wind\";")},
+		}))
+		compiler.Compile(program)
+
+		assert.Equal(t, []string{"[file c.wind:3:1]: circular include: a.wind:2 -> b.wind:1 -> c.wind:3 -> a.wind"}, compiler.ReportErrors(), file)
+	}
+}
+
+func TestImportsStayInTheirFile(t *testing.T) {
+	files := fstest.MapFS{
+		"a.wind": {Data: []byte(`
+			include "./b.wind";
+			export let reveal = fn() { return secret; };
+		`)},
+		"b.wind": {Data: []byte(`export let secret = 42;`)},
+		"c.wind": {Data: []byte(`export let peek = fn() { return secret; };`)},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+		message  string
+	}{
+		{`include "./a.wind"; include "./c.wind"; println(peek());`, "", "identifier not found: secret"},
+		{`include "./a.wind"; println(secret);`, "", "identifier not found: secret"},
+		{`include "./a.wind"; println(reveal());`, "42\n", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		parser := parser.New(lexer.New(tt.input), "main.wind")
+		program := parser.ParseProgram()
+		assert.Empty(t, parser.ReportErrors(), tt.input)
+
+		compiler := compiler.NewCompiler("main.wind")
+		compiler.SetLoader(loader.NewFS(files))
+		main := compiler.Compile(program)
+		assert.Empty(t, compiler.ReportErrors(), tt.input)
+
+		v := NewVM(compiler.Constants)
+		v.SetStdout(&out)
+
+		err := v.Interpret(main)
+		if tt.message != "" {
+			rtErr, ok := err.(*RuntimeError)
+			if assert.True(t, ok, tt.input) {
+				assert.Equal(t, tt.message, rtErr.Message, tt.input)
+			}
+
+			continue
+		}
+
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+}
+
+func TestExports(t *testing.T) {
+	files := fstest.MapFS{
+		"lib.wind": {Data: []byte(`
+			let helper = fn(x) { x * 2 };
+			export let double = fn(x) { helper(x) };
+			export const name = "lib";
+		`)},
+		"all.wind": {Data: []byte(`let everything = 1;`)},
+		"wrap.wind": {Data: []byte(`
+			include "./all.wind";
+			export let wrapped = everything;
+		`)},
+	}
+
+	// reading what a module doesn't export fails when it runs, like in the evaluator
+	tests := []struct {
+		input    string
+		expected string
+		message  string
+	}{
+		{`include "./lib.wind" import { double, name as libName }; println(double(2), libName);`, "4 lib\n", ""},
+		{`include "./lib.wind" as lib; println(lib.double(lib.name.len()));`, "6\n", ""},
+		{`include "./lib.wind"; println(double(5));`, "10\n", ""},
+		{`include "math" import { abs as absolute }; println(absolute(-1.5));`, "1.500000\n", ""},
+		{`include "./lib.wind" import { helper };`, "", "helper is not exported by lib.wind"},
+		{`include "./lib.wind" import { missing };`, "", "include key not found: missing"},
+		{`include "./lib.wind" as lib; lib.helper(1);`, "", "helper is not exported by lib.wind"},
+		{`include "./lib.wind" as lib; lib.println(1);`, "", "include key not found: println"},
+		{`include "./wrap.wind" as wrap; wrap.everything;`, "", "include key not found: everything"},
+		{`include "./lib.wind" as lib; try { lib.helper(1); } catch (e) { println(e["message"]); }`, "helper is not exported by lib.wind\n", ""},
+		{`try { include "./lib.wind" import { helper }; } catch (e) { println(e["message"]); }`, "helper is not exported by lib.wind\n", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		parser := parser.New(lexer.New(tt.input), "main.wind")
+		program := parser.ParseProgram()
+		assert.Empty(t, parser.ReportErrors(), tt.input)
+
+		compiler := compiler.NewCompiler("main.wind")
+		compiler.SetLoader(loader.NewFS(files))
+		main := compiler.Compile(program)
+		assert.Empty(t, compiler.ReportErrors(), tt.input)
+
+		v := NewVM(compiler.Constants)
+		v.SetStdout(&out)
+
+		err := v.Interpret(main)
+		if tt.message != "" {
+			rtErr, ok := err.(*RuntimeError)
+			if assert.True(t, ok, tt.input) {
+				assert.Equal(t, tt.message, rtErr.Message, tt.input)
+			}
+
+			continue
+		}
+
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+
+	err := interpret(t, `include "math" import { missing };`)
+	assert.ErrorContains(t, err, "include key not found: missing")
+}
+
+func TestBreakContinue(t *testing.T) {
+	// break and continue close the blocks they leave, so the variables declared after the loop use the slots of the right scope
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (let i = 0; i < 10; i++) { let a = i; { let b = a * 2; if (b > 4) { let c = b; break; } } } let after = 7; let more = 8; println(after, more);`, "7 8\n"},
+		{`let before = 1; for (let i = 0; i < 3; i++) { let x = i; if (x == 1) { let y = x; { let z = y; continue; } } print(x); } let after = 2; println(before, after);`, "021 2\n"},
+		{`let i = 0; while (true) { let a = i; i++; { let b = a; if (b == 3) { break; } } } let c = i * 10; let d = fn() { c + i }; println(c, d());`, "40 44\n"},
+		{`for (let i = 0; i < 2; i++) { { let t = i; if (t == 0) { continue; } break; } } let x = "x"; for (let j = 0; j < 1; j++) { let y = x + "y"; print(y); } let z = "z"; println(x, z);`, "xyx z\n"},
+		{`let total = 0; for (let i = 0; i < 3; i++) { for (let j = 0; j < 3; j++) { let p = i * j; { if (p >= 2) { let q = p; break; } } total += p; } } let after = total; println(after);`, "1\n"},
+		{`let f = fn() { for (x in [1, 2, 3]) { let y = x; { if (y == 2) { let z = y; break; } } } let after = "after"; after }; println(f());`, "after\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+		assert.Equal(t, 0, v.EnvStack.p, tt.input)
+	}
+}
+
+func TestTryFinally(t *testing.T) {
+	// every finally block prints once, leaving a try with break, continue or return runs it before leaving
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (let i = 0; i < 5; i++) { try { if (i == 2) { break; } print(i); } finally { print("f"); } } print("end");`, "0f1ffend"},
+		{`for (let i = 0; i < 3; i++) { try { if (i == 1) { continue; } print(i); } finally { print("f"); } }`, "0ff2f"},
+		{`let i = 0; while (i < 3) { i++; try { if (i == 2) { continue; } print(i); } finally { print("f"); } }`, "1ff3f"},
+		{`let f = fn() { try { return 1; } finally { print("f"); } }; print(f());`, "f1"},
+		{`let x = 1; let f = fn() { try { return x; } finally { x = 2; print("f"); } }; println(f(), x);`, "f1 2\n"},
+		{`for (x in [1, 2]) { try { throw x; } catch (e) { print(e); break; } finally { print("f"); } }`, "1f"},
+		// nested try blocks run their finally blocks from the innermost out
+		{`let f = fn() { try { try { return "in"; } finally { print("a"); } } finally { print("b"); } }; print(f());`, "abin"},
+		{`for (let i = 0; i < 3; i++) { try { try { if (i == 1) { break; } } finally { print("a"); } } finally { print("b"); } } print("end");`, "ababend"},
+		{`for (let i = 0; i < 2; i++) { try { try { continue; } finally { print("a"); } print("x"); } finally { print("b"); } }`, "abab"},
+		{`try { for (x in [1, 2, 3]) { try { if (x == 2) { break; } print(x); } finally { print("i"); } } print("after"); } finally { print("o"); }`, "1iiaftero"},
+		{`let f = fn() { for (x in [1, 2]) { try { for (y in [3, 4]) { try { return x * y; } finally { print("a"); } } } finally { print("b"); } } }; print(f());`, "ab3"},
+		{`let f = fn() { try { try { throw "e"; } finally { print("a"); } } catch (e) { return e; } finally { print("b"); } }; print(f());`, "abe"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+}
+
+func TestForIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (x in [1, 2, 3]) { print(x * 2); }`, "246"},
+		{`for (i, x in ["a", "b"]) { println(i, x); }`, "0 a\n1 b\n"},
+		{`for (k in {"b": 2, "a": 1}) { print(k); }`, "ab"},
+		{`for (k, v in {"b": 2, "a": 1}) { println(k, v); }`, "a 1\nb 2\n"},
+		{`for (i, ch in "hé") { println(i, ch); }`, "0 h\n1 é\n"},
+		{`for (i in 2..5) { print(i); }`, "234"},
+		{`for (n, i in 5..8) { println(n, i); }`, "0 5\n1 6\n2 7\n"},
+		{`for (i in 5..3) { print(i); }`, ""},
+		{`for (i in 0..10) { if (i % 2 == 0) { continue; } if (i > 5) { break; } print(i); }`, "135"},
+		{`for (i in 0..3) { for (j in 0..3) { if (j == 1) { break; } println(i, j); } }`, "0 0\n1 0\n2 0\n"},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } }; print(f());`, "20"},
+		{`let fns = []; for (x in [1, 2]) { fns.push(fn() { x }); } println(fns[0](), fns[1]());`, "1 2\n"},
+		{`for (x in [1, 2]) { try { throw x; } catch (e) { print(e); continue; } }`, "12"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+
+	assert.ErrorContains(t, interpret(t, `for (x in 1) {}`), "cannot iterate over INTEGER")
+	assert.ErrorContains(t, interpret(t, `for (i in 0..1.5) {}`), "range bounds must be integers, got INTEGER..FLOAT")
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`println(0..5, 0..=5, (0..10).step(2), 3..);`, "0..5 0..=5 (0..10).step(2) 3..\n"},
+		{`println((0..=5).toArray(), (5..=0).step(-2).toArray());`, "[0,1,2,3,4,5,] [5,3,1,]\n"},
+		{`println((0..10).len(), (0..=10).len(), (5..0).len(), (0..10).step(4).len());`, "10 11 0 3\n"},
+		{`println((0..10)[3], (0..10)[10], (0..10).step(2)[4], (1..)[100]);`, "3 nil 8 101\n"},
+		{`println((0..10).contains(9), (0..10).contains(10), (0..10).step(3).contains(4), (0..).contains(1000));`, "true false false true\n"},
+		{`let a = [1, 2, 3, 4, 5]; println(a[1..3], a[3..], a[-2..2], a[(4..).step(-2)]);`, "[2,3,] [4,5,] [1,2,] [5,3,1,]\n"},
+		{`let s = "héllo"; println(s[1..3], s[2..], s[0..=1]);`, "él llo hé\n"},
+		{`for (i in (0..10).step(4)) { print(i); }`, "048"},
+		{`for (i in 3..) { if (i > 5) { break; } print(i); }`, "345"},
+		{`for (n, i in 5..=7) { println(n, i); }`, "0 5\n1 6\n2 7\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+
+	assert.ErrorContains(t, interpret(t, `let r = "a"..3;`), "range bounds must be integers, got STRING..INTEGER")
+	assert.ErrorContains(t, interpret(t, `(0..).len();`), "an open range has no length")
+	assert.ErrorContains(t, interpret(t, `(0..3).step(0);`), "the step of a range cannot be 0")
+}
+
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = {"name": "Joe"}; let count = 3; print("Hello ${user.name}, you have ${count} items");`, "Hello Joe, you have 3 items"},
+		{`print("${1 + 2}${"b"}${nil} ${true} ${0..3}");`, "3bnil true 0..3"},
+		{`let x = 2; print("a ${"b ${x * 2} c"} d");`, "a b 4 c d"},
+		{`print("${ {"k": "v"}["k"] }");`, "v"},
+		{`let f = fn(n) { "n=${n}" }; print(f(1) + f(2));`, "n=1n=2"},
+		{`let out = []; let s = "${out.push(1)}${out.push(2)}"; print(out);`, "[1,2,]"},
+		{"print(`raw ${x} \\n \"`);", `raw ${x} \n "`},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; print(x);`, "2"},
+		{`let s = "a"; s += "b"; print(s);`, "ab"},
+		{`let f = 1.5; f += 1; f++; ++f; print(f);`, "4.500000"},
+		{`let a = [1, 2, 3]; a[0] += 10; a[1]++; --a[2]; print(a);`, "[11,3,2,]"},
+		{`let h = {"n": 1}; h["n"] += 1; h.n *= 5; h.n++; print(h.n);`, "11"},
+		{`let x = 5; println(++x, x++, x, --x, x--, x);`, "6 6 7 6 6 5\n"},
+		{`let a = [0]; let i = 0; a[i++] += 1; println(a, i);`, "[1,] 1\n"},
+		{`let n = 0; let add = fn() { n += 2; ++n; }; add(); print(n);`, "3"},
+		{`let d = 10; try { d /= 0; } catch (e) { println(e["message"], d); }`, "division by zero 10\n"},
+		{`let a = [10]; try { a[0] %= 0; } catch (e) { println(e["message"], a); }`, "division by zero [10,]\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		v, main := newVM(t, tt.input)
+		v.SetStdout(&out)
+
+		assert.Nil(t, v.Interpret(main), tt.input)
+		assert.Equal(t, tt.expected, out.String(), tt.input)
+	}
+
+	assert.ErrorContains(t, interpret(t, `let s = "a"; s++;`), "postfix operator not supported: a")
+	assert.ErrorContains(t, interpret(t, `let a = [1]; a[5] += 1;`), "unsupported operands NIL + INTEGER")
+}
@@ -0,0 +1,199 @@
+// Package wind embeds the Wind interpreter in go programs
+//
+//	rt := wind.NewRuntime()
+//	if err := rt.RunFile("script.wind"); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	result, err := rt.Call("add", 1, 2)
+package wind
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joetifa2003/windlang/evaluator"
+	"github.com/joetifa2003/windlang/lexer"
+	"github.com/joetifa2003/windlang/loader"
+	"github.com/joetifa2003/windlang/parser"
+	"github.com/joetifa2003/windlang/sandbox"
+)
+
+// goFile is the file reported for the code called from go
+const goFile = "<go>"
+
+// Runtime runs scripts with the tree walking interpreter, the variables declared
+// by the scripts it runs are its globals and stay available between runs
+type Runtime struct {
+	envManager  *evaluator.EnvironmentManager
+	env         *evaluator.Environment
+	stdout      io.Writer
+	stdin       *bufio.Reader
+	limits      sandbox.Limits
+	permissions *sandbox.Permissions
+}
+
+// SyntaxError holds the errors found while parsing a script
+type SyntaxError struct {
+	Errors []string
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		envManager: evaluator.NewEnvironmentManager(),
+		env:        evaluator.NewEnvironment(),
+		stdout:     os.Stdout,
+		stdin:      bufio.NewReader(os.Stdin),
+	}
+}
+
+// SetStdout makes the scripts write their output to w instead of the standard output
+func (r *Runtime) SetStdout(w io.Writer) {
+	r.stdout = w
+}
+
+// SetStdin makes the scripts read their input from reader instead of the standard input
+func (r *Runtime) SetStdin(reader io.Reader) {
+	r.stdin = bufio.NewReader(reader)
+}
+
+// SetLimits bounds the work of each run and call, a script that goes over them
+// fails with an error wrapping sandbox.ErrLimitExceeded
+func (r *Runtime) SetLimits(limits sandbox.Limits) {
+	r.limits = limits
+}
+
+// SetPermissions restricts the std library modules, the files and the network the scripts can access,
+// a script that goes over them fails with an error whose message starts with "permission denied".
+// By default the scripts can access everything
+func (r *Runtime) SetPermissions(permissions *sandbox.Permissions) {
+	r.permissions = permissions
+}
+
+// SetLoader changes where RunFile and the includes of the scripts read the files from,
+// loader.NewFS reads them from an fs.FS like an embed.FS
+//
+//	//go:embed scripts
+//	var scripts embed.FS
+//
+//	rt.SetLoader(loader.NewFS(scripts))
+//	rt.RunFile("scripts/main.wind")
+func (r *Runtime) SetLoader(l loader.ModuleLoader) {
+	r.envManager.SetLoader(l)
+}
+
+func (r *Runtime) newEvaluator(ctx context.Context, filePath string) *evaluator.Evaluator {
+	ev := evaluator.New(r.envManager, filePath)
+	ev.SetStdout(r.stdout)
+	ev.SetStdin(r.stdin)
+	ev.SetLimits(ctx, r.limits)
+	ev.SetPermissions(r.permissions)
+
+	return ev
+}
+
+// RunFile runs a script file read by the loader of the runtime, the includes of the script are relative to it
+func (r *Runtime) RunFile(filePath string) error {
+	return r.RunFileContext(context.Background(), filePath)
+}
+
+// RunFileContext is like RunFile but stops the script with an error wrapping sandbox.ErrCancelled when ctx is done
+func (r *Runtime) RunFileContext(ctx context.Context, filePath string) error {
+	source, err := r.envManager.Load(filePath)
+	if err != nil {
+		return err
+	}
+
+	return r.run(ctx, source, filePath)
+}
+
+// RunString runs the source of a script, the includes of the script are relative to the working directory
+func (r *Runtime) RunString(source string) error {
+	return r.RunStringContext(context.Background(), source)
+}
+
+// RunStringContext is like RunString but stops the script with an error wrapping sandbox.ErrCancelled when ctx is done
+func (r *Runtime) RunStringContext(ctx context.Context, source string) error {
+	return r.run(ctx, source, "<string>")
+}
+
+func (r *Runtime) run(ctx context.Context, source string, filePath string) error {
+	parser := parser.New(lexer.New(source), filePath)
+	program := parser.ParseProgram()
+	if errors := parser.ReportErrors(); len(errors) > 0 {
+		return &SyntaxError{Errors: errors}
+	}
+
+	r.envManager.SetSource(filePath, source)
+	ev := r.newEvaluator(ctx, filePath)
+	if _, err := ev.Eval(program, r.env, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Call calls the global function with the given name, the arguments
+// and the result are converted like Set and Get do
+func (r *Runtime) Call(fnName string, args ...interface{}) (interface{}, error) {
+	return r.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is like Call but stops the function with an error wrapping sandbox.ErrCancelled when ctx is done
+func (r *Runtime) CallContext(ctx context.Context, fnName string, args ...interface{}) (interface{}, error) {
+	fn, ok := r.env.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", fnName)
+	}
+
+	objects := make([]evaluator.Object, len(args))
+	for i, arg := range args {
+		obj, err := evaluator.ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		objects[i] = obj
+	}
+
+	ev := r.newEvaluator(ctx, goFile)
+	result, evErr := ev.CallFunction(fn, objects...)
+	if evErr != nil {
+		return nil, evErr
+	}
+
+	return evaluator.ToInterface(result), nil
+}
+
+// Get returns the value of a global converted to go by evaluator.ToInterface, ok is false if it's not declared
+func (r *Runtime) Get(name string) (value interface{}, ok bool) {
+	obj, ok := r.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+
+	return evaluator.ToInterface(obj), true
+}
+
+// Set declares a global or replaces its value, the value is converted by evaluator.ToObject
+// so go functions and structs can be exposed to the scripts as they are
+//
+//	rt.Set("upper", strings.ToUpper)
+func (r *Runtime) Set(name string, value interface{}) error {
+	obj, err := evaluator.ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	r.env.Let(name, obj)
+
+	return nil
+}
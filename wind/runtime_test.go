@@ -0,0 +1,157 @@
+package wind
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/joetifa2003/windlang/evaluator"
+	"github.com/joetifa2003/windlang/loader"
+	"github.com/joetifa2003/windlang/sandbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStringAndCall(t *testing.T) {
+	assert := assert.New(t)
+
+	rt := NewRuntime()
+	assert.Nil(rt.Set("base", 10))
+	assert.Nil(rt.RunString(`
+		let add = fn(a, b) { base + a + b };
+		let names = ["a", "b"];
+		let point = {"x": 1.5, "ok": true};
+	`))
+
+	result, err := rt.Call("add", 1, 2)
+	assert.Nil(err)
+	assert.Equal(13, result)
+
+	names, ok := rt.Get("names")
+	assert.True(ok)
+	assert.Equal([]interface{}{"a", "b"}, names)
+
+	point, ok := rt.Get("point")
+	assert.True(ok)
+	assert.Equal(map[string]interface{}{"x": 1.5, "ok": true}, point)
+
+	_, ok = rt.Get("missing")
+	assert.False(ok)
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	rt := NewRuntime()
+
+	err := rt.RunString("let = 1;")
+	_, ok := err.(*SyntaxError)
+	assert.True(ok)
+
+	err = rt.RunString("let f = fn() { throw \"boom\"; };")
+	assert.Nil(err)
+
+	_, err = rt.Call("f")
+	evErr, ok := err.(*evaluator.Error)
+	if assert.True(ok) {
+		assert.Equal("uncaught exception: boom", evErr.Message)
+	}
+
+	_, err = rt.Call("missing")
+	assert.EqualError(err, "identifier not found: missing")
+
+	err = rt.Set("ch", make(chan int))
+	assert.EqualError(err, "cannot convert chan int to a Wind value")
+}
+
+func TestStdio(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	rt := NewRuntime()
+	rt.SetStdout(&out)
+	rt.SetStdin(strings.NewReader("first\nsecond\n"))
+
+	assert.Nil(rt.RunString(`echo input("> ");`))
+	assert.Nil(rt.RunString(`let greet = fn() { println("hi", input()) };`))
+
+	_, err := rt.Call("greet")
+	assert.Nil(err)
+	assert.Equal("> first\nhi second\n", out.String())
+}
+
+func TestCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	rt := NewRuntime()
+	assert.Nil(rt.RunString(`let spin = fn() { while (true) {} };`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := rt.CallContext(ctx, "spin")
+	assert.ErrorIs(err, sandbox.ErrCancelled)
+
+	rt.SetLimits(sandbox.Limits{MaxSteps: 100})
+	err = rt.RunString(`spin();`)
+	assert.ErrorIs(err, sandbox.ErrLimitExceeded)
+}
+
+func TestPermissions(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+
+	rt := NewRuntime()
+	rt.SetPermissions(&sandbox.Permissions{FSRoots: []string{dir}})
+	assert.Nil(rt.Set("path", path))
+
+	assert.Nil(rt.RunString(`include "fs" as fs; fs.writeFile(path, "hello");`))
+	assert.Nil(rt.RunString(`let content = fs.readFile(path);`))
+
+	content, _ := rt.Get("content")
+	assert.Equal("hello", content)
+
+	err := rt.RunString(`fs.readFile("/etc/hostname");`)
+	assert.ErrorContains(err, "permission denied: cannot access /etc/hostname")
+
+	err = rt.RunString(`include "request" as request; request.get("http://localhost");`)
+	assert.ErrorContains(err, "permission denied: network access to http://localhost is not allowed")
+}
+
+func TestLoader(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+
+	rt := NewRuntime()
+	rt.SetStdout(&out)
+	rt.SetLoader(loader.NewFS(fstest.MapFS{
+		"scripts/main.wind":     {Data: []byte(`include "./lib/math.wind" as m; println(m.square(3));`)},
+		"scripts/lib/math.wind": {Data: []byte(`include "../../shared.wind"; let square = fn(x) { mul(x, x) };`)},
+		"shared.wind":           {Data: []byte(`let mul = fn(a, b) { a * b };`)},
+	}))
+
+	assert.Nil(rt.RunFile("scripts/main.wind"))
+	assert.Equal("9\n", out.String())
+
+	err := rt.RunString(`include "missing.wind";`)
+	assert.ErrorContains(err, "cannot read file: missing.wind")
+
+	rt.SetLoader(loader.NewFS(fstest.MapFS{
+		"main.wind": {Data: []byte(`include "./bad.wind"; println("ran");`)},
+		"bad.wind":  {Data: []byte(`let x = ;`)},
+	}))
+
+	out.Reset()
+	err = rt.RunFile("main.wind")
+	assert.ErrorContains(err, "syntax errors in bad.wind")
+	assert.ErrorContains(err, "no prefix parse function for ;")
+	assert.Empty(out.String())
+}